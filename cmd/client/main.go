@@ -1,18 +1,91 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"strconv"
+	"time"
 
 	netpkg "code_breaker/internal/net"
 )
 
 func main() {
-	addr := os.Getenv("SERVER_ADDR")
-	if addr == "" {
-		addr = "localhost:8080"
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		if err := netpkg.RunReplay(os.Args[2:], os.Stdout); err != nil {
+			if !errors.Is(err, flag.ErrHelp) {
+				fmt.Fprintln(os.Stderr, "replay:", err)
+			}
+			os.Exit(2)
+		}
+		return
 	}
-	if err := netpkg.StartClient(addr); err != nil {
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		if err := netpkg.RunValidate(os.Args[2:], os.Stdout); err != nil {
+			if !errors.Is(err, flag.ErrHelp) {
+				fmt.Fprintln(os.Stderr, "validate:", err)
+			}
+			os.Exit(2)
+		}
+		return
+	}
+
+	cfg := netpkg.ClientConfig{}
+	flag.StringVar(&cfg.Address, "addr", envString("SERVER_ADDR", "localhost:8080"), "server address (env SERVER_ADDR)")
+	flag.BoolVar(&cfg.TLS, "tls", envBool("TLS", false), "connect over TLS (env TLS)")
+	flag.StringVar(&cfg.CAFile, "ca", envString("TLS_CA_FILE", ""), "PEM bundle of the CAs to trust, default the system roots (env TLS_CA_FILE)")
+	flag.BoolVar(&cfg.InsecureSkipVerify, "insecure", envBool("TLS_INSECURE_SKIP_VERIFY", false), "accept any server certificate, for development only (env TLS_INSECURE_SKIP_VERIFY)")
+	flag.StringVar(&cfg.Name, "name", envString("PLAYER_NAME", ""), "nickname, 2-16 letters, digits, '-' or '_' (env PLAYER_NAME)")
+	flag.StringVar(&cfg.Password, "password", envString("PLAYER_PASSWORD", ""), "password of the account named -name (env PLAYER_PASSWORD)")
+	flag.BoolVar(&cfg.Register, "register", envBool("REGISTER", false), "register the account named -name with -password (env REGISTER)")
+	flag.StringVar(&cfg.Locale, "locale", envString("LOCALE", ""), "preferred locale, e.g. en or en-US (env LOCALE)")
+	flag.BoolVar(&cfg.Color, "color", envBool("COLOR", true), "the terminal renders ANSI colors (env COLOR)")
+	flag.StringVar(&cfg.Token, "token", envString("SESSION_TOKEN", ""), "session token to resume (env SESSION_TOKEN)")
+	flag.StringVar(&cfg.Room, "room", envString("ROOM", ""), "room to join on connect, empty to pick one in the lobby (env ROOM)")
+	flag.StringVar(&cfg.Watch, "watch", envString("WATCH", ""), "room to watch as a spectator (env WATCH)")
+	flag.BoolVar(&cfg.QuickPlay, "play", envBool("QUICK_PLAY", false), "join the quick-play queue on connect (env QUICK_PLAY)")
+	flag.StringVar(&cfg.PlayDifficulty, "play-difficulty", envString("PLAY_DIFFICULTY", ""), "quick-play preferred difficulty (env PLAY_DIFFICULTY)")
+	flag.IntVar(&cfg.PlayPlayers, "play-players", envInt("PLAY_PLAYERS", 0), "quick-play preferred number of players (env PLAY_PLAYERS)")
+	flag.BoolVar(&cfg.Reconnect, "reconnect", envBool("RECONNECT", true), "reconnect automatically when the server drops (env RECONNECT)")
+	flag.IntVar(&cfg.MaxRetries, "max-retries", envInt("RECONNECT_MAX_RETRIES", 10), "reconnect attempts before giving up, 0 retries forever (env RECONNECT_MAX_RETRIES)")
+	flag.DurationVar(&cfg.InitialBackoff, "backoff", envDuration("RECONNECT_BACKOFF", 500*time.Millisecond), "delay before the first reconnect attempt (env RECONNECT_BACKOFF)")
+	flag.DurationVar(&cfg.MaxBackoff, "max-backoff", envDuration("RECONNECT_MAX_BACKOFF", 30*time.Second), "maximum delay between reconnect attempts (env RECONNECT_MAX_BACKOFF)")
+	flag.Parse()
+
+	if err := netpkg.StartClient(cfg); err != nil {
 		fmt.Println("client error:", err)
 	}
 }
+
+// Helpers
+func envString(name string, defaultVal string) string {
+	if val := os.Getenv(name); val != "" {
+		return val
+	}
+	return defaultVal
+}
+
+func envInt(name string, defaultVal int) int {
+	n, err := strconv.Atoi(os.Getenv(name))
+	if err != nil {
+		return defaultVal
+	}
+	return n
+}
+
+func envBool(name string, defaultVal bool) bool {
+	b, err := strconv.ParseBool(os.Getenv(name))
+	if err != nil {
+		return defaultVal
+	}
+	return b
+}
+
+func envDuration(name string, defaultVal time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(name))
+	if err != nil {
+		return defaultVal
+	}
+	return d
+}
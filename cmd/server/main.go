@@ -1,9 +1,33 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"code_breaker/internal/game"
 	"code_breaker/internal/net"
 )
 
 func main() {
-	netpkg.StartServer()
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		if err := netpkg.RunExport(os.Args[2:], os.Stdout); err != nil {
+			if !errors.Is(err, flag.ErrHelp) {
+				fmt.Fprintln(os.Stderr, "export:", err)
+			}
+			os.Exit(2)
+		}
+		return
+	}
+
+	cfg := game.LoadServerConfig()
+	listen := flag.String("listen", strings.Join(cfg.ListenAddrs, ","),
+		"comma-separated game listener addresses: host:port, [ipv6]:port or unix:/path (env LISTEN_ADDR)")
+	flag.IntVar(&cfg.HTTPPort, "http-port", cfg.HTTPPort, "port of the web client, WebSocket and API server, 0 disables it (env HTTP_PORT)")
+	flag.Parse()
+	cfg.ListenAddrs = strings.Split(*listen, ",")
+
+	netpkg.StartServer(cfg)
 }
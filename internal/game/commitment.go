@@ -0,0 +1,22 @@
+package game
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+)
+
+// CommitSecret returns the commitment to a secret published when its game starts: the hex SHA-256
+// of the salt, a colon and the secret with its leading zeros. Revealing the salt once the game is
+// over lets anyone check that the secret was not changed in between.
+func CommitSecret(secret, codeLength int, salt string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s:%0*d", salt, codeLength, secret)))
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyCommitment reports whether commitment was made to secret with salt.
+func VerifyCommitment(commitment, salt string, secret, codeLength int) bool {
+	expected := CommitSecret(secret, codeLength, salt)
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(commitment)) == 1
+}
@@ -1,9 +1,11 @@
 package game
 
 import (
+	"errors"
 	"log"
 	"os"
 	"strconv"
+	"strings"
 )
 
 type Config struct {
@@ -11,6 +13,12 @@ type Config struct {
 	CodeLength      int
 	Difficulty      Difficulty
 	TurnTimeSeconds int
+	// SpectatorSecretDelaySeconds reveals the secret to spectators this long into a game; negative never does.
+	SpectatorSecretDelaySeconds int
+	ChatMaxLength               int // longest chat message accepted, in characters
+	// Scoring plays matches of MatchGames games in which players earn points for their guesses
+	Scoring    bool
+	MatchGames int
 }
 
 func LoadConfig() Config {
@@ -19,10 +27,119 @@ func LoadConfig() Config {
 		CodeLength:      envInt("CODE_LENGTH", 4),
 		Difficulty:      envDifficulty("DIFFICULTY", DifficultyMedium),
 		TurnTimeSeconds: envInt("TURN_TIME_SECONDS", 30),
+
+		SpectatorSecretDelaySeconds: envInt("SPECTATOR_SECRET_DELAY_SECONDS", -1),
+		ChatMaxLength:               envInt("CHAT_MAX_LENGTH", 200),
+
+		Scoring:    envBool("SCORING", false),
+		MatchGames: envInt("MATCH_GAMES", 3),
+	}
+}
+
+// MatchmakingConfig controls the quick-play queue.
+type MatchmakingConfig struct {
+	WaitSeconds  int  // how long a queue waits before starting with whoever is present
+	FillWithBots bool // fill the empty seats of a queue that timed out with bots
+}
+
+func LoadMatchmakingConfig() MatchmakingConfig {
+	return MatchmakingConfig{
+		WaitSeconds:  envInt("QUEUE_WAIT_SECONDS", 30),
+		FillWithBots: envBool("QUEUE_FILL_WITH_BOTS", false),
+	}
+}
+
+// ServerConfig holds the settings of the server process, as opposed to those of a room.
+type ServerConfig struct {
+	ListenAddrs []string // addresses of the game listeners: host:port, [ipv6]:port or unix:/path
+	HTTPPort    int      // port of the HTTP server accepting WebSocket clients; 0 disables it
+	AdminToken  string   // bearer token of the admin API; empty disables it
+	// AccountsFile stores the registered accounts; empty disables accounts
+	AccountsFile string
+	// AnalyticsFile stores the records of all games; empty keeps them in memory only
+	AnalyticsFile string
+	// ReplayDir receives a replay file of every game; empty disables replays
+	ReplayDir string
+
+	// TLS is used when a certificate is given or TLSSelfSigned is set
+	TLSCertFile   string
+	TLSKeyFile    string
+	TLSSelfSigned bool // generate a self-signed certificate, for development
+
+	// a shutdown closes all rooms within ShutdownTimeoutSeconds of the signal
+	ShutdownTimeoutSeconds int
+	ShutdownFinishTurn     bool // let the turns in progress end before their rooms close
+
+	LogLevel     string // debug, info, warn or error
+	LogFormat    string // logfmt or json
+	DebugSecrets bool   // log the secret codes instead of redacting them
+}
+
+func LoadServerConfig() ServerConfig {
+	return ServerConfig{
+		ListenAddrs: envList("LISTEN_ADDR", []string{":8080"}),
+		HTTPPort:    envInt("HTTP_PORT", 8081),
+		AdminToken:  os.Getenv("ADMIN_TOKEN"),
+
+		AccountsFile:  os.Getenv("ACCOUNTS_FILE"),
+		AnalyticsFile: os.Getenv("ANALYTICS_FILE"),
+		ReplayDir:     os.Getenv("REPLAY_DIR"),
+
+		TLSCertFile:   os.Getenv("TLS_CERT_FILE"),
+		TLSKeyFile:    os.Getenv("TLS_KEY_FILE"),
+		TLSSelfSigned: envBool("TLS_SELF_SIGNED", false),
+
+		ShutdownTimeoutSeconds: envInt("SHUTDOWN_TIMEOUT_SECONDS", 25),
+		ShutdownFinishTurn:     envBool("SHUTDOWN_FINISH_TURN", true),
+
+		LogLevel:     envString("LOG_LEVEL", "info"),
+		LogFormat:    envString("LOG_FORMAT", "logfmt"),
+		DebugSecrets: envBool("DEBUG_SECRETS", false),
+	}
+}
+
+// Validate reports settings a game cannot be played with.
+func (c Config) Validate() error {
+	switch {
+	case c.MaxPlayers < 1:
+		return errors.New("max players must be at least 1")
+	case c.CodeLength < 2 || c.CodeLength > 8:
+		return errors.New("code length must be between 2 and 8")
+	case c.Difficulty != DifficultyEasy && c.Difficulty != DifficultyMedium && c.Difficulty != DifficultyHard:
+		return errors.New("difficulty must be easy, medium or hard")
+	case c.Difficulty == DifficultyHard && c.CodeLength < 3:
+		return errors.New("hard difficulty must have at least 3 digits")
+	case c.TurnTimeSeconds < 1:
+		return errors.New("turn time must be at least 1 second")
+	case c.ChatMaxLength < 1:
+		return errors.New("chat max length must be at least 1")
+	case c.Scoring && c.MatchGames < 1:
+		return errors.New("a match must have at least 1 game")
+	}
+	return nil
+}
+
+// ParseDifficulty accepts a difficulty name in lower or upper case.
+func ParseDifficulty(val string) (Difficulty, bool) {
+	switch val {
+	case "easy", "EASY":
+		return DifficultyEasy, true
+	case "medium", "MEDIUM":
+		return DifficultyMedium, true
+	case "hard", "HARD":
+		return DifficultyHard, true
 	}
+	return "", false
 }
 
 // Helpers
+func envString(name string, defaultVal string) string {
+	if val := os.Getenv(name); val != "" {
+		return val
+	}
+	return defaultVal
+}
+
 func envInt(name string, defaultVal int) int {
 	val := os.Getenv(name)
 	if val == "" {
@@ -36,15 +153,36 @@ func envInt(name string, defaultVal int) int {
 	return n
 }
 
-func envDifficulty(name string, defaultVal Difficulty) Difficulty {
+func envBool(name string, defaultVal bool) bool {
 	val := os.Getenv(name)
-	switch val {
-	case "easy", "EASY":
-		return DifficultyEasy
-	case "medium", "MEDIUM":
-		return DifficultyMedium
-	case "hard", "HARD":
-		return DifficultyHard
+	if val == "" {
+		return defaultVal
+	}
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		log.Printf("Invalid %s=%s, using default %t", name, val, defaultVal)
+		return defaultVal
+	}
+	return b
+}
+
+// envList reads a comma-separated list.
+func envList(name string, defaultVal []string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(name), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	if len(list) == 0 {
+		return defaultVal
+	}
+	return list
+}
+
+func envDifficulty(name string, defaultVal Difficulty) Difficulty {
+	if d, ok := ParseDifficulty(os.Getenv(name)); ok {
+		return d
 	}
 	return defaultVal
 }
@@ -14,15 +14,34 @@ type Feedback struct {
 // GenerateFeedback compares secret vs guess and returns counts and a hint.
 // RNG is injected to allow deterministic tests.
 func GenerateFeedback(secret, guess int, rng *rand.Rand) Feedback {
-	secretDigits := splitToDigits(secret)
-	guessDigits := splitToDigits(guess)
+	return defaultSpec().Feedback(secret, guess, rng)
+}
+
+// Feedback compares secret vs guess as codes of the spec's length.
+func (c CodeSpec) Feedback(secret, guess int, rng *rand.Rand) Feedback {
+	secretDigits := c.split(secret)
+	guessDigits := c.split(guess)
+	correctPlace, wrongPlace := scoreDigits(secretDigits, guessDigits)
+
+	hint := GenerateSmartHint(secretDigits, guessDigits, rng)
+
+	return Feedback{
+		CorrectPlace: correctPlace,
+		WrongPlace:   wrongPlace,
+		Hint:         hint,
+	}
+}
 
+// Score counts the guess digits in the correct place and those present in the wrong place.
+func (c CodeSpec) Score(secret, guess int) (correctPlace, wrongPlace int) {
+	return scoreDigits(c.split(secret), c.split(guess))
+}
+
+func scoreDigits(secretDigits, guessDigits []int) (correctPlace, wrongPlace int) {
+	codeDigits := len(secretDigits)
 	usedSecret := make([]bool, codeDigits)
 	usedGuess := make([]bool, codeDigits)
 
-	correctPlace := 0
-	wrongPlace := 0
-
 	// exact matches
 	for i := 0; i < codeDigits; i++ {
 		if secretDigits[i] == guessDigits[i] {
@@ -49,19 +68,13 @@ func GenerateFeedback(secret, guess int, rng *rand.Rand) Feedback {
 			}
 		}
 	}
-
-	hint := GenerateSmartHint(secretDigits, guessDigits, rng)
-
-	return Feedback{
-		CorrectPlace: correctPlace,
-		WrongPlace:   wrongPlace,
-		Hint:         hint,
-	}
+	return correctPlace, wrongPlace
 }
 
 // GenerateSmartHint builds possible hints and returns ONE randomized hint.
 // It mirrors the original logic.
 func GenerateSmartHint(secretDigits, guessDigits []int, rng *rand.Rand) string {
+	codeDigits := len(secretDigits)
 	var hints []string
 
 	// First / second half placement
@@ -202,3 +202,136 @@ func TestGenerateSecret_HardDifficulty_PanicsIfTooShort(t *testing.T) {
 		"Expected panic when using Hard difficulty with a code length < 3",
 	)
 }
+
+func TestSuggestGuess_SolvesWithConsistentGuesses(t *testing.T) {
+	spec := NewCodeSpec(4)
+	rng := rand.New(rand.NewSource(7))
+	for _, secret := range []int{1234, 9009, 7777, 5061} {
+		var history []GuessRecord
+		solved := false
+		for turn := 0; turn < 15 && !solved; turn++ {
+			guess := spec.SuggestGuess(history, rng)
+			correct, wrong := spec.Score(secret, guess)
+			history = append(history, GuessRecord{Guess: guess, CorrectPlace: correct, WrongPlace: wrong})
+			solved = correct == spec.Digits
+		}
+		assert.True(t, solved, "secret %d not solved in 15 guesses", secret)
+	}
+}
+
+func TestScore_MatchesFeedback(t *testing.T) {
+	spec := NewCodeSpec(4)
+	correct, wrong := spec.Score(1234, 1393)
+	assert.Equal(t, 1, correct)
+	assert.Equal(t, 1, wrong)
+
+	f := spec.Feedback(1234, 4321, rand.New(rand.NewSource(1)))
+	correct, wrong = spec.Score(1234, 4321)
+	assert.Equal(t, f.CorrectPlace, correct)
+	assert.Equal(t, f.WrongPlace, wrong)
+}
+
+func testNotation() GameNotation {
+	secret := 4213
+	return GameNotation{
+		Room: "main", Game: 3, Date: time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC),
+		CodeLength: 4, Difficulty: DifficultyEasy,
+		Players: []NotationPlayer{{Name: "alice"}, {Name: "bot-2", Bot: true}},
+		Result:  "alice",
+		Secret:  &secret,
+		Guesses: []GuessRecord{
+			{Player: 1, PlayerName: "alice", Guess: 1234, CorrectPlace: 1, WrongPlace: 3, Hint: "More odd digits"},
+			{Player: 2, PlayerName: "bot-2", Guess: 567, CorrectPlace: 0, WrongPlace: 0},
+			{Player: 1, PlayerName: "alice", Guess: 4213, CorrectPlace: 4, WrongPlace: 0, Hint: "Correct {sort of}"},
+		},
+	}
+}
+
+func TestGameNotation_FormatAndParse(t *testing.T) {
+	g := testNotation()
+	text := g.Format()
+	assert.Equal(t, `[Room "main"]
+[Game "3"]
+[Date "2026-01-02T15:04:05Z"]
+[CodeLength "4"]
+[Difficulty "easy"]
+[Players "alice, bot-2 (bot)"]
+[Result "alice"]
+[Secret "4213"]
+
+1. alice 1234 1/3 {More odd digits}
+2. bot-2 0567 0/0
+3. alice 4213 4/0 {Correct (sort of)}
+`, text)
+
+	ongoing := GameNotation{Room: "side room", Game: 1, CodeLength: 3, Difficulty: DifficultyHard,
+		Players: []NotationPlayer{{Name: "bob"}}, Result: ResultOngoing}
+	games, err := ParseNotation(strings.NewReader(FormatNotations([]GameNotation{g, ongoing, g})))
+	require.NoError(t, err)
+	require.Len(t, games, 3)
+	g.Guesses[2].Hint = "Correct (sort of)"
+	assert.Equal(t, g, games[0])
+	assert.Equal(t, ongoing, games[1])
+	assert.Equal(t, g, games[2])
+
+	_, err = ParseNotation(strings.NewReader("[Room \"main\"]\n\n1. alice 12x4 0/0\n"))
+	assert.EqualError(t, err, `line 3: invalid code "12x4"`)
+	_, err = ParseNotation(strings.NewReader("[Room \"main\"]\n\n2. alice 1234 0/0\n"))
+	assert.EqualError(t, err, "line 3: guess number 2, expected 1")
+	_, err = ParseNotation(strings.NewReader("\n"))
+	assert.EqualError(t, err, "no game found")
+}
+
+func TestGameNotation_Validate(t *testing.T) {
+	require.NoError(t, testNotation().Validate())
+
+	hidden := testNotation()
+	hidden.Secret = nil
+	hidden.Result = ResultOngoing
+	hidden.Guesses = hidden.Guesses[:2]
+	assert.NoError(t, hidden.Validate())
+
+	g := testNotation()
+	g.Guesses[0].CorrectPlace = 0
+	g.Guesses[1].PlayerName = "carol"
+	err := g.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "guess 1: 1234 scores 1/3 against the secret, not 0/3")
+	assert.Contains(t, err.Error(), "guess 2: carol is not a player of the game")
+
+	g = testNotation()
+	g.Result = "bot-2"
+	assert.EqualError(t, g.Validate(), "bot-2 won, but guess 3 by alice found the secret")
+
+	g = testNotation()
+	g.Result = ResultAbandoned
+	assert.EqualError(t, g.Validate(), "the game was abandoned, but guess 3 found the secret")
+
+	g = testNotation()
+	g.Guesses = append(g.Guesses, GuessRecord{PlayerName: "bot-2", Guess: 1111, CorrectPlace: 1})
+	assert.EqualError(t, g.Validate(), "guess 4: made after the secret was found by guess 3")
+
+	g = testNotation()
+	secret := 4413
+	g.Secret = &secret
+	g.Guesses = nil
+	g.Result = ResultAbandoned
+	assert.EqualError(t, g.Validate(), "secret 4413 repeats a digit, which easy secrets never do")
+}
+
+func TestCommitSecret(t *testing.T) {
+	// printf 'salt:4213' | sha256sum
+	commitment := CommitSecret(4213, 4, "salt")
+	assert.Equal(t, "58c9636a950d36a94c1260c5eb2a166424f228be21ddc657852398c06eb406f5", commitment)
+	assert.True(t, VerifyCommitment(commitment, "salt", 4213, 4))
+	assert.False(t, VerifyCommitment(commitment, "salt", 4231, 4))
+	assert.False(t, VerifyCommitment(commitment, "other", 4213, 4))
+	assert.NotEqual(t, commitment, CommitSecret(4213, 4, "other"))
+
+	g := testNotation()
+	g.Salt = "salt"
+	g.Commitment = commitment
+	require.NoError(t, g.Validate())
+	g.Commitment = CommitSecret(4231, 4, "salt")
+	assert.EqualError(t, g.Validate(), "secret 4213 does not match the commitment made when the game started")
+}
@@ -4,18 +4,130 @@ package game
 type MessageType string
 
 const (
-	TURN     MessageType = "TURN"
-	WAIT     MessageType = "WAIT"
-	INFO     MessageType = "INFO"
-	RESULT   MessageType = "RESULT"
-	WIN      MessageType = "WIN"
-	NEWGAME  MessageType = "NEWGAME"
-	TIMEOUT  MessageType = "TIMEOUT"
-	RECOVERY MessageType = "RECOVERY"
+	TURN        MessageType = "TURN"
+	WAIT        MessageType = "WAIT"
+	INFO        MessageType = "INFO"
+	RESULT      MessageType = "RESULT"
+	WIN         MessageType = "WIN"
+	NEWGAME     MessageType = "NEWGAME"
+	TIMEOUT     MessageType = "TIMEOUT"
+	RECOVERY    MessageType = "RECOVERY"
+	SESSION     MessageType = "SESSION"
+	SNAPSHOT    MessageType = "SNAPSHOT"
+	LOBBY       MessageType = "LOBBY"
+	SECRET      MessageType = "SECRET"
+	CHAT        MessageType = "CHAT"
+	SHUTDOWN    MessageType = "SHUTDOWN"
+	LEADERBOARD MessageType = "LEADERBOARD"
+	MATCH       MessageType = "MATCH"
+	NOTATION    MessageType = "NOTATION"
+	WATCHING    MessageType = "WATCHING" // a spectator's SESSION: there is no seat, Room names the room watched
+)
+
+// Commands a client may send instead of a guess.
+// A new connection starts in the lobby with a handshake: CmdHello followed by a nickname
+// (a guest name is assigned without one),
+// CmdRegister or CmdLogin followed by a nickname and password to use an account,
+// or CmdResume followed by a session token to reclaim a seat, all optionally followed
+// by preferences (locale=xx, color=true|false). It then lists, creates and joins rooms.
+// CmdLeaderboard, optionally followed by a difficulty, works in the lobby and in rooms.
+// CmdCancel leaves the quick-play queue joined with CmdPlay.
+// CmdNotation, optionally followed by "last", asks a room for a game in notation; the reply is always a NOTATION message.
+const (
+	CmdHello       = "/hello"
+	CmdRegister    = "/register"
+	CmdLogin       = "/login"
+	CmdResume      = "/resume"
+	CmdRooms       = "/rooms"
+	CmdCreate      = "/create"
+	CmdJoin        = "/join"
+	CmdPlay        = "/play"
+	CmdCancel      = "/cancel"
+	CmdWatch       = "/watch"
+	CmdSay         = "/say"
+	CmdMute        = "/mute"
+	CmdUnmute      = "/unmute"
+	CmdLeaderboard = "/leaderboard"
+	CmdNotation    = "/notation"
 )
 
 // Message is the JSON-serializable message sent to clients.
 type Message struct {
-	Type MessageType `json:"type"`
-	Text string      `json:"text"`
+	Type            MessageType  `json:"type"`
+	Text            string       `json:"text"`
+	Token           string       `json:"token,omitempty"`
+	From            string       `json:"from,omitempty"`            // CHAT sender
+	Player          string       `json:"player,omitempty"`          // TURN and WAIT: whose turn it is
+	TimeLeftSeconds int          `json:"timeLeftSeconds,omitempty"` // TURN and WAIT: time for the turn, 0 without a limit; SHUTDOWN: time until the server stops
+	Guess           *GuessRecord `json:"guess,omitempty"`           // RESULT and WIN: the evaluated guess
+	Snapshot        *Snapshot    `json:"snapshot,omitempty"`
+	Rooms           []RoomInfo   `json:"rooms,omitempty"`
+	Leaderboard     *Leaderboard `json:"leaderboard,omitempty"`
+	Scores          []Score      `json:"scores,omitempty"`     // scoring mode: the running scores, with RESULT, WIN, TIMEOUT and MATCH
+	Commitment      string       `json:"commitment,omitempty"` // NEWGAME when a game starts: the commitment to its secret, see CommitSecret
+	Secret          int          `json:"secret,omitempty"`     // WIN: the secret
+	CodeLength      int          `json:"codeLength,omitempty"` // NEWGAME and WIN: the digits of the secret, for CommitSecret
+	Notation        string       `json:"notation,omitempty"`   // NOTATION: the game asked for, empty if there is none and Text says why
+	Salt            string       `json:"salt,omitempty"`       // WIN: the salt of the commitment
+	Room            string       `json:"room,omitempty"`       // WATCHING: the room watched
+}
+
+// RoomInfo describes a room as listed in the lobby.
+type RoomInfo struct {
+	Name            string     `json:"name"`
+	Players         int        `json:"players"`
+	MaxPlayers      int        `json:"maxPlayers"`
+	CodeLength      int        `json:"codeLength"`
+	Difficulty      Difficulty `json:"difficulty"`
+	TurnTimeSeconds int        `json:"turnTimeSeconds"`
+	Spectators      int        `json:"spectators"`
+	Started         bool       `json:"started"`
+	Scoring         bool       `json:"scoring,omitempty"`
+	MatchGames      int        `json:"matchGames,omitempty"` // with scoring, the games of a match
+}
+
+// Leaderboard lists the best rated players of a difficulty.
+type Leaderboard struct {
+	Difficulty Difficulty    `json:"difficulty"`
+	Players    []RatedPlayer `json:"players"`
+}
+
+// RatedPlayer is a player's place on a leaderboard.
+type RatedPlayer struct {
+	Rank   int    `json:"rank"`
+	Name   string `json:"name"`
+	Rating int    `json:"rating"`
+	Games  int    `json:"games"` // rated games, those with at least two players
+	Wins   int    `json:"wins"`
+}
+
+// GuessRecord is one evaluated guess of the current game.
+type GuessRecord struct {
+	Player       int    `json:"player"`
+	PlayerName   string `json:"playerName"`
+	Guess        int    `json:"guess"`
+	CorrectPlace int    `json:"correctPlace"`
+	WrongPlace   int    `json:"wrongPlace"`
+	Hint         string `json:"hint"`
+}
+
+// Snapshot describes the game in progress, sent to a player who rejoins
+// and to a spectator who starts watching (PlayerID 0).
+type Snapshot struct {
+	PlayerID        int           `json:"playerId"`
+	PlayerName      string        `json:"playerName"`
+	CurrentTurn     int           `json:"currentTurn"`
+	CurrentTurnName string        `json:"currentTurnName"`
+	TimeLeftSeconds int           `json:"timeLeftSeconds"`
+	History         []GuessRecord `json:"history"`
+	Secret          int           `json:"secret,omitempty"` // spectators only, once revealed
+	Scores          []Score       `json:"scores,omitempty"`
+	Commitment      string        `json:"commitment,omitempty"` // the commitment to the secret of the game
+}
+
+// Score is a player's points in scoring mode.
+type Score struct {
+	Name  string `json:"name"`
+	Game  int    `json:"game"`  // points in the current game
+	Match int    `json:"match"` // points in the current match, this game included
 }
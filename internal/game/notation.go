@@ -0,0 +1,393 @@
+package game
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The notation writes a game as text, like chess's PGN: tag lines for the settings, the players
+// and the result, a blank line, then one line per guess with the player, the code, the correctly
+// and wrongly placed digits and the hint in braces:
+//
+//	[Room "main"]
+//	[Game "3"]
+//	[Date "2026-01-02T15:04:05Z"]
+//	[CodeLength "4"]
+//	[Difficulty "medium"]
+//	[Players "alice, bot-1 (bot)"]
+//	[Result "alice"]
+//	[Secret "4213"]
+//	[Commitment "5c1e..."]
+//	[Salt "9f0a..."]
+//
+//	1. alice 1234 1/3 {The secret has more odd digits}
+//	2. bot-1 4231 2/2 {...}
+//	3. alice 4213 4/0 {Correct!}
+//
+// A file may hold several games separated by blank lines.
+
+// Results of a game without a winner.
+const (
+	ResultAbandoned = "abandoned" // the game ended without a winner
+	ResultOngoing   = "*"         // the game is still being played
+)
+
+// NotationExtension is the file extension of game notation files.
+const NotationExtension = ".cbn"
+
+// secretHidden is the secret tag of a game whose secret is not revealed.
+const secretHidden = "?"
+
+// NotationPlayer is a seated player of a game in notation.
+type NotationPlayer struct {
+	Name string
+	Bot  bool
+}
+
+// GameNotation is a game as written in the notation.
+type GameNotation struct {
+	Room       string
+	Game       int
+	Date       time.Time // when the game started, zero if unknown
+	CodeLength int
+	Difficulty Difficulty
+	Players    []NotationPlayer // in seat order: seat 1 first
+	Result     string           // the winner's name, ResultAbandoned or ResultOngoing
+	Secret     *int             // nil while hidden
+	Commitment string           // the commitment to the secret published when the game started, see CommitSecret
+	Salt       string           // the salt of the commitment, revealed with the secret
+	Guesses    []GuessRecord
+}
+
+// Format writes the game in notation.
+func (g GameNotation) Format() string {
+	var b strings.Builder
+	tag := func(name, val string) {
+		fmt.Fprintf(&b, "[%s %s]\n", name, strconv.Quote(val))
+	}
+	tag("Room", g.Room)
+	tag("Game", strconv.Itoa(g.Game))
+	if !g.Date.IsZero() {
+		tag("Date", g.Date.UTC().Format(time.RFC3339))
+	}
+	tag("CodeLength", strconv.Itoa(g.CodeLength))
+	tag("Difficulty", string(g.Difficulty))
+	players := make([]string, 0, len(g.Players))
+	for _, p := range g.Players {
+		if p.Bot {
+			players = append(players, p.Name+" (bot)")
+		} else {
+			players = append(players, p.Name)
+		}
+	}
+	tag("Players", strings.Join(players, ", "))
+	result := g.Result
+	if result == "" {
+		result = ResultOngoing
+	}
+	tag("Result", result)
+	if g.Secret != nil {
+		tag("Secret", fmt.Sprintf("%0*d", g.CodeLength, *g.Secret))
+	} else {
+		tag("Secret", secretHidden)
+	}
+	if g.Commitment != "" {
+		tag("Commitment", g.Commitment)
+	}
+	if g.Salt != "" {
+		tag("Salt", g.Salt)
+	}
+
+	b.WriteString("\n")
+	for i, guess := range g.Guesses {
+		fmt.Fprintf(&b, "%d. %s %0*d %d/%d", i+1, guess.PlayerName, g.CodeLength, guess.Guess, guess.CorrectPlace, guess.WrongPlace)
+		if guess.Hint != "" {
+			// braces end the hint, so none may appear inside it
+			fmt.Fprintf(&b, " {%s}", strings.NewReplacer("{", "(", "}", ")").Replace(guess.Hint))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// FormatNotations writes games in notation, separated by blank lines.
+func FormatNotations(games []GameNotation) string {
+	texts := make([]string, 0, len(games))
+	for _, g := range games {
+		texts = append(texts, g.Format())
+	}
+	return strings.Join(texts, "\n")
+}
+
+// ParseNotation reads the games of a notation file. It checks the syntax only; Validate checks
+// that a game is consistent.
+func ParseNotation(in io.Reader) ([]GameNotation, error) {
+	var games []GameNotation
+	var cur *GameNotation
+	afterTags := false // a blank line or a guess followed the tags of cur
+	scanner := bufio.NewScanner(in)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		var err error
+		switch {
+		case text == "":
+			afterTags = cur != nil
+			continue
+		case strings.HasPrefix(text, "["):
+			if cur == nil || afterTags {
+				games = append(games, GameNotation{Result: ResultOngoing})
+				cur = &games[len(games)-1]
+				afterTags = false
+			}
+			err = cur.parseTag(text)
+		default:
+			if cur == nil {
+				err = errors.New("guess before the tags of a game")
+				break
+			}
+			afterTags = true
+			err = cur.parseGuess(text)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(games) == 0 {
+		return nil, errors.New("no game found")
+	}
+	return games, nil
+}
+
+func (g *GameNotation) parseTag(text string) error {
+	if !strings.HasSuffix(text, "]") {
+		return fmt.Errorf("invalid tag %q", text)
+	}
+	name, quoted, ok := strings.Cut(strings.TrimSpace(text[1:len(text)-1]), " ")
+	if !ok {
+		return fmt.Errorf("invalid tag %q", text)
+	}
+	val, err := strconv.Unquote(strings.TrimSpace(quoted))
+	if err != nil {
+		return fmt.Errorf("invalid value of tag %s: %s", name, quoted)
+	}
+
+	switch name {
+	case "Room":
+		g.Room = val
+	case "Game":
+		g.Game, err = strconv.Atoi(val)
+	case "Date":
+		g.Date, err = time.Parse(time.RFC3339, val)
+	case "CodeLength":
+		g.CodeLength, err = strconv.Atoi(val)
+	case "Difficulty":
+		d, ok := ParseDifficulty(val)
+		if !ok {
+			return fmt.Errorf("invalid difficulty %q", val)
+		}
+		g.Difficulty = d
+	case "Players":
+		g.Players = nil
+		for _, name := range strings.Split(val, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			p := NotationPlayer{Name: name}
+			if trimmed := strings.TrimSuffix(name, " (bot)"); trimmed != name {
+				p = NotationPlayer{Name: strings.TrimSpace(trimmed), Bot: true}
+			}
+			g.Players = append(g.Players, p)
+		}
+	case "Result":
+		g.Result = val
+	case "Commitment":
+		g.Commitment = val
+	case "Salt":
+		g.Salt = val
+	case "Secret":
+		if val == secretHidden {
+			g.Secret = nil
+			return nil
+		}
+		var secret int
+		secret, err = parseCode(val)
+		g.Secret = &secret
+	default:
+		// unknown tags are allowed, as in PGN
+	}
+	if err != nil {
+		return fmt.Errorf("invalid value of tag %s: %q", name, val)
+	}
+	return nil
+}
+
+// parseGuess reads a guess line: "N. player code correct/wrong {hint}".
+func (g *GameNotation) parseGuess(text string) error {
+	hint := ""
+	if open := strings.Index(text, "{"); open >= 0 {
+		if !strings.HasSuffix(text, "}") {
+			return errors.New("hint not closed with }")
+		}
+		hint = text[open+1 : len(text)-1]
+		text = strings.TrimSpace(text[:open])
+	}
+	fields := strings.Fields(text)
+	if len(fields) != 4 {
+		return fmt.Errorf("invalid guess %q, expected: number. player code correct/wrong {hint}", text)
+	}
+	number, err := strconv.Atoi(strings.TrimSuffix(fields[0], "."))
+	if err != nil || !strings.HasSuffix(fields[0], ".") {
+		return fmt.Errorf("invalid guess number %q", fields[0])
+	}
+	if number != len(g.Guesses)+1 {
+		return fmt.Errorf("guess number %d, expected %d", number, len(g.Guesses)+1)
+	}
+	code, err := parseCode(fields[2])
+	if err != nil {
+		return err
+	}
+	if g.CodeLength > 0 && len(fields[2]) != g.CodeLength {
+		return fmt.Errorf("code %s does not have %d digits", fields[2], g.CodeLength)
+	}
+	correct, wrong, ok := strings.Cut(fields[3], "/")
+	record := GuessRecord{PlayerName: fields[1], Guess: code, Hint: hint}
+	if ok {
+		record.CorrectPlace, err = strconv.Atoi(correct)
+		if err == nil {
+			record.WrongPlace, err = strconv.Atoi(wrong)
+		}
+	}
+	if !ok || err != nil {
+		return fmt.Errorf("invalid feedback %q, expected correct/wrong", fields[3])
+	}
+	for i, p := range g.Players {
+		if p.Name == record.PlayerName {
+			record.Player = i + 1
+		}
+	}
+	g.Guesses = append(g.Guesses, record)
+	return nil
+}
+
+func parseCode(val string) (int, error) {
+	for _, ch := range val {
+		if ch < '0' || ch > '9' {
+			return 0, fmt.Errorf("invalid code %q", val)
+		}
+	}
+	code, err := strconv.Atoi(val)
+	if err != nil {
+		return 0, fmt.Errorf("invalid code %q", val)
+	}
+	return code, nil
+}
+
+// Validate checks that the game is consistent: every guess was made by a seated player and fits
+// the code length, its feedback matches the revealed secret, the secret fits the difficulty and
+// its commitment, and the result agrees with the guesses. Hints are not checked, they are chosen at random.
+// It returns all the problems found, joined.
+func (g GameNotation) Validate() error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+	if g.CodeLength < 2 || g.CodeLength > 8 {
+		fail("code length %d is not between 2 and 8", g.CodeLength)
+		return errors.Join(errs...)
+	}
+	spec := NewCodeSpec(g.CodeLength)
+	if g.Difficulty != DifficultyEasy && g.Difficulty != DifficultyMedium && g.Difficulty != DifficultyHard {
+		fail("invalid difficulty %q", g.Difficulty)
+	}
+	if len(g.Players) == 0 {
+		fail("no players")
+	}
+	seated := make(map[string]bool, len(g.Players))
+	for _, p := range g.Players {
+		if seated[p.Name] {
+			fail("player %s is seated twice", p.Name)
+		}
+		seated[p.Name] = true
+	}
+
+	if g.Secret != nil {
+		secret := *g.Secret
+		switch {
+		case secret < spec.Min || secret > spec.Max:
+			fail("secret %0*d is not a %d-digit code", g.CodeLength, secret, g.CodeLength)
+		case g.Difficulty == DifficultyEasy && spec.hasRepeatingDigit(secret):
+			fail("secret %d repeats a digit, which easy secrets never do", secret)
+		case g.Difficulty == DifficultyHard && !spec.hasRepeatingDigit(secret):
+			fail("secret %d repeats no digit, which hard secrets always do", secret)
+		}
+		if g.Commitment != "" && g.Salt != "" && !VerifyCommitment(g.Commitment, g.Salt, secret, g.CodeLength) {
+			fail("secret %0*d does not match the commitment made when the game started", g.CodeLength, secret)
+		}
+	}
+
+	solvedAt := 0
+	for i, guess := range g.Guesses {
+		n := i + 1
+		if !seated[guess.PlayerName] {
+			fail("guess %d: %s is not a player of the game", n, guess.PlayerName)
+		}
+		if guess.Guess < 0 || guess.Guess > spec.Max {
+			fail("guess %d: %d is not a %d-digit code", n, guess.Guess, g.CodeLength)
+			continue
+		}
+		if guess.CorrectPlace < 0 || guess.WrongPlace < 0 || guess.CorrectPlace+guess.WrongPlace > g.CodeLength {
+			fail("guess %d: feedback %d/%d is impossible with %d digits", n, guess.CorrectPlace, guess.WrongPlace, g.CodeLength)
+			continue
+		}
+		if solvedAt > 0 {
+			fail("guess %d: made after the secret was found by guess %d", n, solvedAt)
+		}
+		if guess.CorrectPlace == g.CodeLength && solvedAt == 0 {
+			solvedAt = n
+		}
+		if g.Secret != nil {
+			correct, wrong := spec.Score(*g.Secret, guess.Guess)
+			if correct != guess.CorrectPlace || wrong != guess.WrongPlace {
+				fail("guess %d: %0*d scores %d/%d against the secret, not %d/%d",
+					n, g.CodeLength, guess.Guess, correct, wrong, guess.CorrectPlace, guess.WrongPlace)
+			}
+		}
+	}
+
+	switch g.Result {
+	case ResultOngoing:
+		if solvedAt > 0 {
+			fail("the game is not over, but guess %d found the secret", solvedAt)
+		}
+	case ResultAbandoned:
+		if solvedAt > 0 {
+			fail("the game was abandoned, but guess %d found the secret", solvedAt)
+		}
+		if g.Secret == nil {
+			fail("the game is over, but its secret is not revealed")
+		}
+	default:
+		if !seated[g.Result] {
+			fail("the winner %s is not a player of the game", g.Result)
+		}
+		if g.Secret == nil {
+			fail("the game is over, but its secret is not revealed")
+		}
+		switch {
+		case solvedAt == 0:
+			fail("%s won, but no guess found the secret", g.Result)
+		case g.Guesses[solvedAt-1].PlayerName != g.Result:
+			fail("%s won, but guess %d by %s found the secret", g.Result, solvedAt, g.Guesses[solvedAt-1].PlayerName)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -50,16 +50,35 @@ var minCode = 1000
 var maxCode = 9999
 var codeRange = 9000 // max-min+1
 
-// SetCodeDigits configures the code length (2..8); panics otherwise (same as original project).
-func SetCodeDigits(digits int) {
+// CodeSpec describes secrets of one length. Unlike the package defaults set by
+// SetCodeDigits it is a plain value, so games with different lengths can run concurrently.
+type CodeSpec struct {
+	Digits int
+	Min    int
+	Max    int
+}
+
+// NewCodeSpec returns the spec for codes of the given length (2..8); panics otherwise.
+func NewCodeSpec(digits int) CodeSpec {
 	if digits < 2 || digits > 8 {
 		panic("code digits must be between 2 and 8")
 	}
-	codeDigits = digits
-	minCode, maxCode = computeCodeBounds(codeDigits)
+	min, max := computeCodeBounds(digits)
+	return CodeSpec{Digits: digits, Min: min, Max: max}
+}
+
+// SetCodeDigits configures the code length (2..8); panics otherwise (same as original project).
+func SetCodeDigits(digits int) {
+	spec := NewCodeSpec(digits)
+	codeDigits = spec.Digits
+	minCode, maxCode = spec.Min, spec.Max
 	codeRange = maxCode - minCode + 1
 }
 
+func defaultSpec() CodeSpec {
+	return CodeSpec{Digits: codeDigits, Min: minCode, Max: maxCode}
+}
+
 func computeCodeBounds(digits int) (min int, max int) {
 	min = 1
 	for i := 1; i < digits; i++ {
@@ -76,10 +95,15 @@ func GenerateTimestampPrefix() string {
 
 // ValidateGuess validates an input guess string according to codeDigits.
 func ValidateGuess(input string) (int, error) {
+	return defaultSpec().ValidateGuess(input)
+}
+
+// ValidateGuess validates an input guess string according to the spec's length.
+func (c CodeSpec) ValidateGuess(input string) (int, error) {
 	trimmed := strings.TrimSpace(input)
 
-	if len(trimmed) != codeDigits {
-		return 0, fmt.Errorf("guess must contain exactly %d digits", codeDigits)
+	if len(trimmed) != c.Digits {
+		return 0, fmt.Errorf("guess must contain exactly %d digits", c.Digits)
 	}
 	for _, ch := range trimmed {
 		if ch < '0' || ch > '9' {
@@ -95,16 +119,22 @@ func ValidateGuess(input string) (int, error) {
 }
 
 // GenerateSecretCodeWithDifficulty implements the original business rules for generating the secret.
-// It loops until a value matching difficulty constraints is produced.
+// It also makes codeLength the package default, as the original did.
 func GenerateSecretCodeWithDifficulty(codeLength int, d Difficulty) int {
-	for {
-		if d == DifficultyHard && codeLength < 3 {
-			panic("Hard difficulty must have at least 3 digits")
-		}
-		SetCodeDigits(codeLength)
+	secret := NewCodeSpec(codeLength).GenerateSecret(d)
+	SetCodeDigits(codeLength)
+	return secret
+}
 
-		base := rand.Intn(codeRange) + minCode
-		digits := splitToDigits(base)
+// GenerateSecret implements the original business rules for generating the secret.
+// It loops until a value matching difficulty constraints is produced.
+func (c CodeSpec) GenerateSecret(d Difficulty) int {
+	if d == DifficultyHard && c.Digits < 3 {
+		panic("Hard difficulty must have at least 3 digits")
+	}
+	for {
+		base := rand.Intn(c.Max-c.Min+1) + c.Min
+		digits := c.split(base)
 		sum := digitSum(digits)
 
 		var modified []int
@@ -117,24 +147,24 @@ func GenerateSecretCodeWithDifficulty(codeLength int, d Difficulty) int {
 		final := digitsToNumber(modified)
 
 		// Palindrome override: convert palindromes to 7777 (same behavior as original)
-		if isPalindrome(final) {
+		if c.isPalindrome(final) {
 			final = 7777
 		}
 
 		// ensure within bounds
-		if final < minCode || final > maxCode {
+		if final < c.Min || final > c.Max {
 			continue
 		}
 
 		switch d {
 		case DifficultyEasy:
-			if !hasRepeatingDigit(final) {
+			if !c.hasRepeatingDigit(final) {
 				return final
 			}
 		case DifficultyMedium:
 			return final
 		case DifficultyHard:
-			if hasRepeatingDigit(final) {
+			if c.hasRepeatingDigit(final) {
 				return final
 			}
 		}
@@ -143,8 +173,12 @@ func GenerateSecretCodeWithDifficulty(codeLength int, d Difficulty) int {
 
 // Helpers
 func splitToDigits(n int) []int {
-	out := make([]int, codeDigits)
-	for i := codeDigits - 1; i >= 0; i-- {
+	return defaultSpec().split(n)
+}
+
+func (c CodeSpec) split(n int) []int {
+	out := make([]int, c.Digits)
+	for i := c.Digits - 1; i >= 0; i-- {
 		out[i] = n % 10
 		n /= 10
 	}
@@ -160,15 +194,15 @@ func digitSum(d []int) int {
 }
 
 func reverseDigits(d []int) []int {
-	out := make([]int, codeDigits)
-	for i := 0; i < codeDigits; i++ {
-		out[i] = d[codeDigits-1-i]
+	out := make([]int, len(d))
+	for i := range d {
+		out[i] = d[len(d)-1-i]
 	}
 	return out
 }
 
 func incrementDigits(d []int) []int {
-	out := make([]int, codeDigits)
+	out := make([]int, len(d))
 	for i, v := range d {
 		if v == 9 {
 			out[i] = 0
@@ -188,7 +222,11 @@ func digitsToNumber(d []int) int {
 }
 
 func isPalindrome(n int) bool {
-	d := splitToDigits(n)
+	return defaultSpec().isPalindrome(n)
+}
+
+func (c CodeSpec) isPalindrome(n int) bool {
+	d := c.split(n)
 	i := 0
 	j := len(d) - 1
 	for i < j {
@@ -202,8 +240,12 @@ func isPalindrome(n int) bool {
 }
 
 func hasRepeatingDigit(n int) bool {
+	return defaultSpec().hasRepeatingDigit(n)
+}
+
+func (c CodeSpec) hasRepeatingDigit(n int) bool {
 	seen := make(map[int]bool)
-	for _, d := range splitToDigits(n) {
+	for _, d := range c.split(n) {
 		if seen[d] {
 			return true
 		}
@@ -0,0 +1,50 @@
+package game
+
+import (
+	"math/rand"
+)
+
+// codes up to this many are enumerated in full, longer codes are sampled
+const maxEnumeratedCodes = 100000
+
+// sampleAttempts bounds the random search for long codes.
+const sampleAttempts = 50000
+
+// SuggestGuess picks a code consistent with every guess and its feedback so far,
+// the way a careful player would. Short codes are enumerated; long ones are sampled,
+// and if no consistent code turns up a random one is returned.
+func (c CodeSpec) SuggestGuess(history []GuessRecord, rng *rand.Rand) int {
+	size := c.Max - c.Min + 1
+	if size <= maxEnumeratedCodes {
+		var candidates []int
+		for code := c.Min; code <= c.Max; code++ {
+			if c.consistent(code, history) {
+				candidates = append(candidates, code)
+			}
+		}
+		if len(candidates) > 0 {
+			return candidates[rng.Intn(len(candidates))]
+		}
+		return c.Min + rng.Intn(size)
+	}
+
+	code := c.Min + rng.Intn(size)
+	for i := 0; i < sampleAttempts; i++ {
+		if c.consistent(code, history) {
+			return code
+		}
+		code = c.Min + rng.Intn(size)
+	}
+	return code
+}
+
+// consistent reports whether code, had it been the secret, would have produced the recorded feedback.
+func (c CodeSpec) consistent(code int, history []GuessRecord) bool {
+	for _, g := range history {
+		correctPlace, wrongPlace := c.Score(code, g.Guess)
+		if correctPlace != g.CorrectPlace || wrongPlace != g.WrongPlace {
+			return false
+		}
+	}
+	return true
+}
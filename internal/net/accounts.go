@@ -0,0 +1,166 @@
+package netpkg
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Password hashing: PBKDF2 with HMAC-SHA256 (RFC 8018) and a random salt per account.
+const (
+	passwordIterations = 100000
+	passwordSaltSize   = 16
+	passwordHashSize   = 32
+	minPasswordLength  = 8
+	maxPasswordLength  = 128
+)
+
+var errInvalidLogin = errors.New("wrong nickname or password")
+
+// account is a registered nickname as stored in the accounts file.
+type account struct {
+	Name       string    `json:"name"`
+	Salt       []byte    `json:"salt"`
+	Hash       []byte    `json:"hash"`
+	Iterations int       `json:"iterations"`
+	Created    time.Time `json:"created"`
+}
+
+// AccountStore keeps the registered accounts in a JSON file, which is rewritten on every registration.
+type AccountStore struct {
+	path string
+
+	mu       sync.Mutex
+	accounts map[string]*account // by lower-case name
+}
+
+// OpenAccountStore loads the accounts file at path; a missing file is an empty store.
+func OpenAccountStore(path string) (*AccountStore, error) {
+	s := &AccountStore{path: path, accounts: make(map[string]*account)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var accounts []*account
+	if err := json.Unmarshal(data, &accounts); err != nil {
+		return nil, fmt.Errorf("invalid accounts file %s: %w", path, err)
+	}
+	for _, a := range accounts {
+		s.accounts[strings.ToLower(a.Name)] = a
+	}
+	return s, nil
+}
+
+// Register creates an account. The nickname must already be validated.
+func (s *AccountStore) Register(name, password string) error {
+	if n := utf8.RuneCountInString(password); n < minPasswordLength || n > maxPasswordLength {
+		return fmt.Errorf("password must be %d-%d characters", minPasswordLength, maxPasswordLength)
+	}
+	salt := make([]byte, passwordSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	a := &account{
+		Name:       name,
+		Salt:       salt,
+		Hash:       pbkdf2SHA256([]byte(password), salt, passwordIterations, passwordHashSize),
+		Iterations: passwordIterations,
+		Created:    time.Now().UTC(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := strings.ToLower(name)
+	if s.accounts[key] != nil {
+		return fmt.Errorf("nickname %s is already registered", name)
+	}
+	s.accounts[key] = a
+	if err := s.saveLocked(); err != nil {
+		delete(s.accounts, key)
+		return fmt.Errorf("error saving account: %w", err)
+	}
+	return nil
+}
+
+// Authenticate checks a password and returns the account's nickname as registered.
+func (s *AccountStore) Authenticate(name, password string) (string, error) {
+	s.mu.Lock()
+	a := s.accounts[strings.ToLower(name)]
+	s.mu.Unlock()
+
+	if a == nil {
+		// hash anyway so unknown nicknames take as long as wrong passwords
+		pbkdf2SHA256([]byte(password), make([]byte, passwordSaltSize), passwordIterations, passwordHashSize)
+		return "", errInvalidLogin
+	}
+	hash := pbkdf2SHA256([]byte(password), a.Salt, a.Iterations, len(a.Hash))
+	if subtle.ConstantTimeCompare(hash, a.Hash) != 1 {
+		return "", errInvalidLogin
+	}
+	return a.Name, nil
+}
+
+// Exists reports whether name, in any case, is registered.
+func (s *AccountStore) Exists(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.accounts[strings.ToLower(name)] != nil
+}
+
+// saveLocked writes all accounts to a temporary file and moves it over the store; s.mu must be held.
+func (s *AccountStore) saveLocked() error {
+	accounts := make([]*account, 0, len(s.accounts))
+	for _, a := range s.accounts {
+		accounts = append(accounts, a)
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Name < accounts[j].Name })
+	data, err := json.MarshalIndent(accounts, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// pbkdf2SHA256 derives a key of keyLen bytes from password and salt (RFC 8018, PBKDF2 with HMAC-SHA256).
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	key := make([]byte, 0, keyLen+prf.Size())
+	var counter [4]byte
+	for block := uint32(1); len(key) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(counter[:], block)
+		prf.Write(counter[:])
+		u := prf.Sum(nil)
+		t := append([]byte{}, u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
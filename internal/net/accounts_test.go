@@ -0,0 +1,152 @@
+package netpkg
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"code_breaker/internal/game"
+)
+
+func TestPBKDF2SHA256(t *testing.T) {
+	for iterations, expected := range map[int]string{
+		1:    "120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b",
+		2:    "ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43",
+		4096: "c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a",
+	} {
+		key := pbkdf2SHA256([]byte("password"), []byte("salt"), iterations, 32)
+		assert.Equal(t, expected, hex.EncodeToString(key), "%d iterations", iterations)
+	}
+	assert.Len(t, pbkdf2SHA256([]byte("password"), []byte("salt"), 1, 40), 40)
+}
+
+func TestAccountStore_RegisterAndAuthenticate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "accounts.json")
+	store, err := OpenAccountStore(path)
+	require.NoError(t, err)
+
+	require.NoError(t, store.Register("Alice", "correct horse"))
+	assert.Error(t, store.Register("alice", "another password"), "names are unique ignoring case")
+	assert.Error(t, store.Register("bob", "short"))
+
+	name, err := store.Authenticate("ALICE", "correct horse")
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", name)
+	_, err = store.Authenticate("alice", "wrong password")
+	assert.ErrorIs(t, err, errInvalidLogin)
+	_, err = store.Authenticate("nobody", "correct horse")
+	assert.ErrorIs(t, err, errInvalidLogin)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "correct horse")
+
+	reopened, err := OpenAccountStore(path)
+	require.NoError(t, err)
+	assert.True(t, reopened.Exists("alice"))
+	_, err = reopened.Authenticate("alice", "correct horse")
+	assert.NoError(t, err)
+}
+
+func TestLobby_AccountsReclaimSeatsByLogin(t *testing.T) {
+	store, err := OpenAccountStore(filepath.Join(t.TempDir(), "accounts.json"))
+	require.NoError(t, err)
+	lobby := NewLobby(testConfig(2), game.MatchmakingConfig{WaitSeconds: 1}, store, nil)
+	_, err = lobby.CreateRoom(DefaultRoom, testConfig(2))
+	require.NoError(t, err)
+	addr := serveTestLobby(t, lobby)
+
+	alice := dialTestClient(t, addr, game.CmdRegister+" alice correct-horse color=false")
+	require.Equal(t, "Welcome alice! You are logged in.\n", alice.expect(game.INFO).Text)
+	alice.send(joinMain)
+	alice.expect(game.SESSION)
+
+	// the registered name is only available through the account
+	impostor := dialTestClient(t, addr, game.CmdHello+" Alice")
+	require.Contains(t, impostor.expect(game.INFO).Text, "belongs to an account")
+	impostor.send(game.CmdLogin + " alice wrong-password")
+	require.Contains(t, impostor.expect(game.INFO).Text, "wrong nickname or password")
+	impostor.send(game.CmdRegister + " alice other-password")
+	require.Contains(t, impostor.expect(game.INFO).Text, "already registered")
+
+	// logging in from a new connection takes the seat back without the session token
+	_ = alice.conn.Close()
+	again := dialTestClient(t, addr, game.CmdLogin+" ALICE correct-horse")
+	again.expect(game.INFO) // welcome
+	require.Equal(t, "You have a seat in room main, /join main to return to it.\n", again.expect(game.INFO).Text)
+	again.send(joinMain)
+	again.expect(game.SESSION)
+
+	bob := dialPlayer(t, addr, "bob", joinMain)
+	bob.expect(game.SESSION)
+	state, err := lobby.room(DefaultRoom).State()
+	require.NoError(t, err)
+	require.Len(t, state.Seats, 2)
+	assert.Equal(t, "alice", state.Seats[0].Name)
+	assert.True(t, state.Seats[0].Connected)
+}
+
+func TestLobby_AccountsLogInFromOneConnectionAtATime(t *testing.T) {
+	store, err := OpenAccountStore(filepath.Join(t.TempDir(), "accounts.json"))
+	require.NoError(t, err)
+	lobby := NewLobby(testConfig(2), game.MatchmakingConfig{WaitSeconds: 1}, store, nil)
+	_, err = lobby.CreateRoom(DefaultRoom, testConfig(2))
+	require.NoError(t, err)
+	addr := serveTestLobby(t, lobby)
+
+	alice := dialTestClient(t, addr, game.CmdRegister+" alice correct-horse")
+	alice.expect(game.INFO) // welcome
+	alice.send(joinMain)
+	alice.expect(game.SESSION)
+	alice.expect(game.INFO) // welcome to the room
+
+	// a second login closes the seated connection, and the seat waits for the new one
+	second := dialTestClient(t, addr, game.CmdLogin+" alice correct-horse")
+	second.expect(game.INFO) // welcome
+	assert.Equal(t, "You logged in to alice from another connection, this one is closed.\n", alice.expect(game.INFO).Text)
+	alice.expectClosed()
+	second.send(joinMain)
+	second.expect(game.SESSION)
+
+	// and in the lobby
+	lobbyOnly := dialTestClient(t, addr, game.CmdLogin+" alice correct-horse")
+	lobbyOnly.expect(game.INFO) // welcome
+	lobbyOnly.expect(game.INFO) // the seat to return to
+	third := dialTestClient(t, addr, game.CmdLogin+" alice correct-horse")
+	third.expect(game.INFO) // welcome
+	second.expectClosed()
+	assert.Contains(t, lobbyOnly.expect(game.INFO).Text, "from another connection")
+	lobbyOnly.expectClosed()
+
+	state, err := lobby.room(DefaultRoom).State()
+	require.NoError(t, err)
+	require.Len(t, state.Seats, 1)
+	assert.Equal(t, "alice", state.Seats[0].Name)
+
+	// a connection that leaves its account for a plain nickname or another account is not closed
+	// by logins to the account it left
+	renamed := dialTestClient(t, addr, game.CmdRegister+" carol correct-horse")
+	renamed.expect(game.LOBBY)
+	renamed.send(game.CmdHello + " bobby")
+	renamed.expect(game.LOBBY)
+	switched := dialTestClient(t, addr, game.CmdRegister+" erin correct-horse")
+	switched.expect(game.LOBBY)
+	switched.send(game.CmdRegister + " frank correct-horse")
+	switched.expect(game.LOBBY)
+	dialTestClient(t, addr, game.CmdLogin+" carol correct-horse").expect(game.INFO)
+	dialTestClient(t, addr, game.CmdLogin+" erin correct-horse").expect(game.INFO)
+	for _, c := range []*testClient{renamed, switched} {
+		c.send(game.CmdRooms)
+		c.expect(game.LOBBY)
+	}
+}
+
+func TestLobby_AccountsDisabled(t *testing.T) {
+	addr := startTestServer(t, testConfig(1))
+	c := dialTestClient(t, addr, game.CmdRegister+" alice correct-horse")
+	assert.Contains(t, c.expect(game.INFO).Text, "accounts are not enabled")
+}
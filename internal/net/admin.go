@@ -0,0 +1,229 @@
+package netpkg
+
+import (
+	"errors"
+	"fmt"
+
+	"code_breaker/internal/game"
+)
+
+// RoomState is a room as seen by the API: its listing plus the game in progress, without the secret.
+type RoomState struct {
+	game.RoomInfo
+	Seats           []SeatState        `json:"seats"`
+	SpectatorNames  []string           `json:"spectatorNames"`
+	GameNumber      int                `json:"gameNumber"`
+	CurrentTurn     string             `json:"currentTurn,omitempty"`
+	TimeLeftSeconds int                `json:"timeLeftSeconds"`
+	Recovering      bool               `json:"recovering"`
+	History         []game.GuessRecord `json:"history"`
+}
+
+// SeatState describes a seat of a room.
+type SeatState struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	Bot       bool   `json:"bot"`
+	Connected bool   `json:"connected"`
+}
+
+// RoomSettings changes some of a room's settings; nil fields keep their value.
+type RoomSettings struct {
+	MaxPlayers                  *int             `json:"maxPlayers,omitempty"`
+	CodeLength                  *int             `json:"codeLength,omitempty"`
+	Difficulty                  *game.Difficulty `json:"difficulty,omitempty"`
+	TurnTimeSeconds             *int             `json:"turnTimeSeconds,omitempty"`
+	SpectatorSecretDelaySeconds *int             `json:"spectatorSecretDelaySeconds,omitempty"`
+	ChatMaxLength               *int             `json:"chatMaxLength,omitempty"`
+	Scoring                     *bool            `json:"scoring,omitempty"`
+	MatchGames                  *int             `json:"matchGames,omitempty"`
+}
+
+func (s RoomSettings) apply(cfg game.Config) game.Config {
+	if s.MaxPlayers != nil {
+		cfg.MaxPlayers = *s.MaxPlayers
+	}
+	if s.CodeLength != nil {
+		cfg.CodeLength = *s.CodeLength
+	}
+	if s.Difficulty != nil {
+		cfg.Difficulty = *s.Difficulty
+	}
+	if s.TurnTimeSeconds != nil {
+		cfg.TurnTimeSeconds = *s.TurnTimeSeconds
+	}
+	if s.SpectatorSecretDelaySeconds != nil {
+		cfg.SpectatorSecretDelaySeconds = *s.SpectatorSecretDelaySeconds
+	}
+	if s.ChatMaxLength != nil {
+		cfg.ChatMaxLength = *s.ChatMaxLength
+	}
+	if s.Scoring != nil {
+		cfg.Scoring = *s.Scoring
+	}
+	if s.MatchGames != nil {
+		cfg.MatchGames = *s.MatchGames
+	}
+	return cfg
+}
+
+// State describes the room and the game in progress.
+func (r *Room) State() (RoomState, error) {
+	var state RoomState
+	err := r.do(func() error {
+		state = RoomState{
+			RoomInfo:        r.Info(),
+			Seats:           make([]SeatState, 0, len(r.players)),
+			SpectatorNames:  make([]string, 0, len(r.spectators)),
+			GameNumber:      r.gameNumber,
+			TimeLeftSeconds: r.timeLeft(),
+			Recovering:      r.recovering,
+			History:         append([]game.GuessRecord{}, r.history...),
+		}
+		for _, p := range r.players {
+			state.Seats = append(state.Seats, SeatState{ID: p.id, Name: p.name, Bot: p.bot, Connected: p.connected()})
+		}
+		for _, s := range r.spectators {
+			state.SpectatorNames = append(state.SpectatorNames, s.name)
+		}
+		if r.started {
+			state.CurrentTurn = r.players[r.currentTurn].name
+		}
+		return nil
+	})
+	return state, err
+}
+
+// Analytics returns a copy of the room's analytics.
+func (r *Room) Analytics() (Analytics, error) {
+	var a Analytics
+	err := r.do(func() error {
+		a = r.analytics.copy()
+		return nil
+	})
+	return a, err
+}
+
+// Kick removes a player or spectator from the room. A kicked player loses the seat and its session.
+func (r *Room) Kick(name string) error {
+	return r.do(func() error {
+		target := r.findByName(name)
+		switch {
+		case target == nil:
+			return fmt.Errorf("nobody called %s is in room %s", name, r.name)
+		case target.bot:
+			return fmt.Errorf("%s is a bot and cannot be kicked", target.name)
+		}
+
+		target.send(game.INFO, fmt.Sprintf("You were removed from room %s by an administrator.\n", r.name))
+		target.disconnect()
+		r.lobby.releaseName(target.name)
+		if target.spectator {
+			r.removeSpectator(target)
+			r.logger().Info("spectator kicked", "name", target.name)
+			return nil
+		}
+
+		r.removeSeat(target)
+		r.logger().Info("player kicked", "name", target.name)
+		r.recordEvent(ReplayEvent{Type: replayLeave, Player: target.name, Reason: "kicked"})
+		r.broadcast(game.INFO, fmt.Sprintf("%s was removed from the room by an administrator.\n", target.name))
+		if r.started && !r.hasHumans() {
+			r.close(game.INFO, fmt.Sprintf("No players are left in room %s.\n", r.name))
+		}
+		return nil
+	})
+}
+
+// removeSeat takes p's seat away and renumbers the seats after it, keeping the turn order.
+func (r *Room) removeSeat(p *Player) {
+	idx := p.id - 1
+	r.mu.Lock()
+	r.players = append(r.players[:idx], r.players[idx+1:]...)
+	for i, other := range r.players {
+		other.id = i + 1
+	}
+	r.mu.Unlock()
+	delete(r.sessions, p.token)
+	r.lobby.removeSession(p.token)
+
+	if !r.started {
+		return
+	}
+	switch {
+	case idx < r.currentTurn:
+		r.currentTurn--
+	case idx == r.currentTurn:
+		// the turn passes to the next seat, which now has this index
+		r.turnInterrupted = true
+		if r.currentTurn >= len(r.players) {
+			r.currentTurn = 0
+		}
+	}
+}
+
+func (r *Room) hasHumans() bool {
+	for _, p := range r.players {
+		if !p.bot {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateSettings changes the room's settings. Before the room starts they apply at once,
+// afterwards from the next game, and the number of players can no longer change.
+func (r *Room) UpdateSettings(s RoomSettings) error {
+	return r.do(func() error {
+		current := r.cfg
+		if r.nextCfg != nil {
+			current = *r.nextCfg
+		}
+		cfg := s.apply(current)
+		if err := cfg.Validate(); err != nil {
+			return err
+		}
+
+		if !r.started {
+			if cfg.MaxPlayers < len(r.players)+r.bots {
+				return fmt.Errorf("%d players are already seated", len(r.players))
+			}
+			r.mu.Lock()
+			r.cfg = cfg
+			r.mu.Unlock()
+			r.code = game.NewCodeSpec(cfg.CodeLength)
+			r.broadcast(game.INFO, "An administrator changed the room settings.\n")
+			return nil
+		}
+
+		if cfg.MaxPlayers != r.cfg.MaxPlayers {
+			return errors.New("the number of players cannot change once the room has started")
+		}
+		r.nextCfg = &cfg
+		r.broadcast(game.INFO, "An administrator changed the room settings. They apply from the next game.\n")
+		return nil
+	})
+}
+
+// close ends the room: everyone in it is told why and disconnected,
+// and the room goroutine exits after the current event.
+func (r *Room) close(msgType game.MessageType, reason string) {
+	if r.started && !r.gameOver && len(r.history) > 0 {
+		r.recordGame(nil)
+	}
+	// a game without guesses is not recorded, but its replay still shows how it ended
+	r.finishReplay(nil)
+	r.broadcast(msgType, reason)
+	r.lobby.removeRoom(r)
+	for _, group := range [][]*Player{r.players, r.spectators} {
+		for _, p := range group {
+			p.disconnect()
+			if !p.bot {
+				r.lobby.releaseName(p.name)
+			}
+		}
+	}
+	r.closing = true
+	close(r.done)
+	r.logger().Info("room closed")
+}
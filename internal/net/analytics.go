@@ -0,0 +1,83 @@
+package netpkg
+
+import "sort"
+
+// Analytics summarizes the games won in a room.
+type Analytics struct {
+	GamesPlayed     int            `json:"gamesPlayed"`
+	WinsByPlayer    map[string]int `json:"winsByPlayer"`
+	LossesByPlayer  map[string]int `json:"lossesByPlayer"`
+	GuessesUntilWin map[int][]int  `json:"guessesUntilWin"` // by secret, one entry per game won
+}
+
+func newAnalytics() *Analytics {
+	return &Analytics{
+		WinsByPlayer:    make(map[string]int),
+		LossesByPlayer:  make(map[string]int),
+		GuessesUntilWin: make(map[int][]int),
+	}
+}
+
+// summarize computes the analytics of the given games.
+func summarize(records []GameRecord) *Analytics {
+	a := newAnalytics()
+	for _, rec := range records {
+		a.add(rec)
+	}
+	return a
+}
+
+// add counts a game; games nobody won are not.
+func (a *Analytics) add(rec GameRecord) {
+	if rec.Winner == "" {
+		return
+	}
+	a.GamesPlayed++
+	a.WinsByPlayer[rec.Winner]++
+	for _, p := range rec.Players {
+		if p.Name != rec.Winner {
+			a.LossesByPlayer[p.Name]++
+		}
+	}
+	a.GuessesUntilWin[rec.Secret] = append(a.GuessesUntilWin[rec.Secret], len(rec.Guesses))
+}
+
+func (a *Analytics) copy() Analytics {
+	c := Analytics{
+		GamesPlayed:     a.GamesPlayed,
+		WinsByPlayer:    make(map[string]int, len(a.WinsByPlayer)),
+		LossesByPlayer:  make(map[string]int, len(a.LossesByPlayer)),
+		GuessesUntilWin: make(map[int][]int, len(a.GuessesUntilWin)),
+	}
+	for name, n := range a.WinsByPlayer {
+		c.WinsByPlayer[name] = n
+	}
+	for name, n := range a.LossesByPlayer {
+		c.LossesByPlayer[name] = n
+	}
+	for secret, guesses := range a.GuessesUntilWin {
+		c.GuessesUntilWin[secret] = append([]int{}, guesses...)
+	}
+	return c
+}
+
+// logAnalytics logs a room's analytics with the five games won that took the most guesses.
+func logAnalytics(l *Logger, a *Analytics) {
+	type hardEntry struct {
+		Secret  int `json:"secret"`
+		Guesses int `json:"guesses"`
+	}
+	hardList := make([]hardEntry, 0)
+	for secret, games := range a.GuessesUntilWin {
+		for _, guesses := range games {
+			hardList = append(hardList, hardEntry{Secret: secret, Guesses: guesses})
+		}
+	}
+
+	sort.Slice(hardList, func(i, j int) bool { return hardList[i].Guesses > hardList[j].Guesses })
+	if len(hardList) > 5 {
+		hardList = hardList[:5]
+	}
+	l.Info("game analytics", "games_played", a.GamesPlayed, "wins_by_player", a.WinsByPlayer,
+		"losses_by_player", a.LossesByPlayer, "hardest_secrets", hardList)
+}
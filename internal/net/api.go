@@ -0,0 +1,188 @@
+package netpkg
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// apiRoomsPath is the root of the HTTP API:
+//
+//	GET    /api/rooms                 list the rooms
+//	POST   /api/rooms                 create a room (admin)
+//	GET    /api/rooms/{room}          players, current turn and guess history
+//	PATCH  /api/rooms/{room}          change the room's settings (admin)
+//	DELETE /api/rooms/{room}          end the room (admin)
+//	GET    /api/rooms/{room}/analytics
+//	POST   /api/rooms/{room}/kick     remove a player or spectator (admin)
+const apiRoomsPath = "/api/rooms"
+
+// apiHandler serves the HTTP API. Admin requests carry "Authorization: Bearer <adminToken>".
+type apiHandler struct {
+	lobby      *Lobby
+	adminToken string
+}
+
+// createRoomRequest is the body of a room creation; unset settings are taken from the server defaults.
+type createRoomRequest struct {
+	Name string `json:"name"`
+	RoomSettings
+}
+
+type kickRequest struct {
+	Player string `json:"player"` // nickname, or seat number
+}
+
+func (h *apiHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, apiRoomsPath), "/")
+	if rest == "" {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, h.lobby.Rooms())
+		case http.MethodPost:
+			if h.authorized(w, r) {
+				h.createRoom(w, r)
+			}
+		default:
+			methodNotAllowed(w, http.MethodGet, http.MethodPost)
+		}
+		return
+	}
+
+	name, action, _ := strings.Cut(rest, "/")
+	room := h.lobby.room(name)
+	if room == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("room %s does not exist", name))
+		return
+	}
+
+	switch action {
+	case "":
+		switch r.Method {
+		case http.MethodGet:
+			state, err := room.State()
+			writeResult(w, http.StatusOK, state, err)
+		case http.MethodPatch:
+			if !h.authorized(w, r) {
+				return
+			}
+			var settings RoomSettings
+			if !readJSON(w, r, &settings) {
+				return
+			}
+			if err := room.UpdateSettings(settings); err != nil {
+				writeRoomError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, room.Info())
+		case http.MethodDelete:
+			if h.authorized(w, r) {
+				writeResult(w, http.StatusNoContent, nil, h.lobby.CloseRoom(name))
+			}
+		default:
+			methodNotAllowed(w, http.MethodGet, http.MethodPatch, http.MethodDelete)
+		}
+
+	case "analytics":
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		analytics, err := room.Analytics()
+		writeResult(w, http.StatusOK, analytics, err)
+
+	case "kick":
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, http.MethodPost)
+			return
+		}
+		if !h.authorized(w, r) {
+			return
+		}
+		var req kickRequest
+		if readJSON(w, r, &req) {
+			writeResult(w, http.StatusNoContent, nil, room.Kick(req.Player))
+		}
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *apiHandler) createRoom(w http.ResponseWriter, r *http.Request) {
+	var req createRoomRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	room, err := h.lobby.CreateRoom(req.Name, req.RoomSettings.apply(h.lobby.defaults))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, room.Info())
+}
+
+// authorized checks the admin token, answering the request itself if it is missing or wrong.
+func (h *apiHandler) authorized(w http.ResponseWriter, r *http.Request) bool {
+	if h.adminToken == "" {
+		writeError(w, http.StatusForbidden, errors.New("the admin API is disabled, set ADMIN_TOKEN to enable it"))
+		return false
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.adminToken)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="codebreaker"`)
+		writeError(w, http.StatusUnauthorized, errors.New("invalid admin token"))
+		return false
+	}
+	return true
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %v", err))
+		return false
+	}
+	return true
+}
+
+// writeResult answers with v, or with err if the room operation failed.
+func writeResult(w http.ResponseWriter, status int, v interface{}, err error) {
+	switch {
+	case err != nil:
+		writeRoomError(w, err)
+	case status == http.StatusNoContent:
+		w.WriteHeader(status)
+	default:
+		writeJSON(w, status, v)
+	}
+}
+
+func writeRoomError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	if errors.Is(err, errRoomClosed) {
+		status = http.StatusNotFound
+	}
+	writeError(w, status, err)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		serverLog.Warn("error writing API response", "err", err)
+	}
+}
+
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+}
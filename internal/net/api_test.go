@@ -0,0 +1,144 @@
+package netpkg
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"code_breaker/internal/game"
+)
+
+const testAdminToken = "secret-token"
+
+type testAPI struct {
+	t   *testing.T
+	url string
+}
+
+// newTestHTTPHandler routes the HTTP requests for lobby, which has no game listeners.
+func newTestHTTPHandler(lobby *Lobby, cfg game.ServerConfig) http.Handler {
+	return newHTTPHandler(lobby, newHealth(lobby), cfg)
+}
+
+// startTestAPI serves lobby over TCP and its API over HTTP.
+func startTestAPI(t *testing.T, lobby *Lobby) (string, *testAPI) {
+	httpServer := httptest.NewServer(newTestHTTPHandler(lobby, game.ServerConfig{AdminToken: testAdminToken}))
+	t.Cleanup(httpServer.Close)
+	return serveTestLobby(t, lobby), &testAPI{t: t, url: httpServer.URL + apiRoomsPath}
+}
+
+// call sends a request with the given token and decodes the response into out, if given.
+func (a *testAPI) call(method, path, token, body string, out interface{}) int {
+	req, err := http.NewRequest(method, a.url+path, strings.NewReader(body))
+	require.NoError(a.t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(a.t, err)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(a.t, err)
+	if out != nil && resp.StatusCode < 300 {
+		require.NoError(a.t, json.Unmarshal(data, out), string(data))
+	}
+	return resp.StatusCode
+}
+
+func TestAPI_ShowsRoomStateWithoutSecret(t *testing.T) {
+	addr, api := startTestAPI(t, newTestLobby(t, testConfig(1)))
+
+	player := dialPlayer(t, addr, "alice", joinMain)
+	player.expect(game.TURN)
+	player.send("0000")
+	player.expect(game.RESULT)
+	player.expect(game.TURN)
+
+	var rooms []game.RoomInfo
+	require.Equal(t, http.StatusOK, api.call(http.MethodGet, "", "", "", &rooms))
+	require.Len(t, rooms, 1)
+	assert.True(t, rooms[0].Started)
+
+	var state RoomState
+	require.Equal(t, http.StatusOK, api.call(http.MethodGet, "/main", "", "", &state))
+	assert.Equal(t, "main", state.Name)
+	assert.Equal(t, []SeatState{{ID: 1, Name: "alice", Connected: true}}, state.Seats)
+	assert.Equal(t, "alice", state.CurrentTurn)
+	assert.Equal(t, 1, state.GameNumber)
+	require.Len(t, state.History, 1)
+	assert.Equal(t, 0, state.History[0].Guess)
+
+	var raw map[string]interface{}
+	require.Equal(t, http.StatusOK, api.call(http.MethodGet, "/main", "", "", &raw))
+	assert.NotContains(t, raw, "secret")
+
+	var analytics Analytics
+	require.Equal(t, http.StatusOK, api.call(http.MethodGet, "/main/analytics", "", "", &analytics))
+	assert.Equal(t, 0, analytics.GamesPlayed)
+
+	assert.Equal(t, http.StatusNotFound, api.call(http.MethodGet, "/nope", "", "", nil))
+}
+
+func TestAPI_AdminRequiresToken(t *testing.T) {
+	_, api := startTestAPI(t, newTestLobby(t, testConfig(1)))
+
+	assert.Equal(t, http.StatusUnauthorized, api.call(http.MethodPost, "", "", `{"name":"x"}`, nil))
+	assert.Equal(t, http.StatusUnauthorized, api.call(http.MethodDelete, "/main", "wrong", "", nil))
+
+	httpServer := httptest.NewServer(newTestHTTPHandler(newTestLobby(t, testConfig(1)), game.ServerConfig{}))
+	t.Cleanup(httpServer.Close)
+	disabled := &testAPI{t: t, url: httpServer.URL + apiRoomsPath}
+	assert.Equal(t, http.StatusForbidden, disabled.call(http.MethodDelete, "/main", "", "", nil))
+}
+
+func TestAPI_AdminManagesRooms(t *testing.T) {
+	addr, api := startTestAPI(t, newTestLobby(t, testConfig(1)))
+
+	var info game.RoomInfo
+	require.Equal(t, http.StatusCreated, api.call(http.MethodPost, "", testAdminToken,
+		`{"name":"duel","maxPlayers":3,"difficulty":"hard"}`, &info))
+	assert.Equal(t, 3, info.MaxPlayers)
+	assert.Equal(t, game.DifficultyHard, info.Difficulty)
+	assert.Equal(t, testDefaults.CodeLength, info.CodeLength)
+	assert.Equal(t, http.StatusBadRequest, api.call(http.MethodPost, "", testAdminToken, `{"codeLength":12}`, nil))
+
+	alice := dialPlayer(t, addr, "alice", game.CmdJoin+" duel")
+	alice.expect(game.SESSION)
+	bob := dialPlayer(t, addr, "bob", game.CmdJoin+" duel")
+	bob.expect(game.SESSION)
+
+	// lowering the seats to the players present starts the game
+	assert.Equal(t, http.StatusBadRequest, api.call(http.MethodPatch, "/duel", testAdminToken, `{"maxPlayers":1}`, nil))
+	require.Equal(t, http.StatusOK, api.call(http.MethodPatch, "/duel", testAdminToken, `{"maxPlayers":2}`, &info))
+	assert.Equal(t, 2, info.MaxPlayers)
+	aliceTurn := alice.expectTurnOrWait()
+	bob.expectTurnOrWait()
+
+	// kicking the player whose turn it is passes the turn on
+	kicked, kickedName, other, otherName := alice, "alice", bob, "bob"
+	if !aliceTurn {
+		kicked, kickedName, other, otherName = bob, "bob", alice, "alice"
+	}
+	require.Equal(t, http.StatusNoContent, api.call(http.MethodPost, "/duel/kick", testAdminToken,
+		`{"player":"`+kickedName+`"}`, nil))
+	assert.Contains(t, kicked.expect(game.INFO).Text, "removed from room duel")
+	other.expect(game.TURN)
+
+	var state RoomState
+	require.Equal(t, http.StatusOK, api.call(http.MethodGet, "/duel", "", "", &state))
+	assert.Equal(t, []SeatState{{ID: 1, Name: otherName, Connected: true}}, state.Seats)
+	assert.Equal(t, http.StatusBadRequest, api.call(http.MethodPost, "/duel/kick", testAdminToken, `{"player":"nobody"}`, nil))
+
+	require.Equal(t, http.StatusNoContent, api.call(http.MethodDelete, "/duel", testAdminToken, "", nil))
+	assert.Contains(t, other.expect(game.INFO).Text, "closed by an administrator")
+	assert.Equal(t, http.StatusNotFound, api.call(http.MethodGet, "/duel", "", "", nil))
+
+	// the names of a closed room are free again
+	dialPlayer(t, addr, otherName)
+}
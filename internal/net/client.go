@@ -3,62 +3,165 @@ package netpkg // Package netpkg to void conflict with standard net package
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math/rand"
 	"net"
 	"os"
 	"strings"
+	"time"
 
 	"code_breaker/internal/game"
 )
 
-// StartClient connects to server and runs the client loop
-func StartClient(address string) error {
-	conn, err := net.Dial("tcp", address)
+var errServerDisconnected = errors.New("server disconnected")
+
+// cmdSave is handled by the client: it saves a game in notation to a file, asking the room
+// for it with game.CmdNotation.
+const cmdSave = "/save"
+
+// ClientConfig controls how the client connects and reconnects.
+type ClientConfig struct {
+	Address            string
+	TLS                bool          // connect over TLS
+	CAFile             string        // PEM bundle of the CAs to trust, empty for the system roots
+	InsecureSkipVerify bool          // accept any server certificate, for development only
+	Name               string        // nickname, empty to get a guest name
+	Password           string        // password of the account named Name, empty to play without an account
+	Register           bool          // register the account instead of logging in to it
+	Locale             string        // preferred locale sent in the handshake, optional
+	Color              bool          // the terminal renders ANSI colors
+	Token              string        // session token to resume, empty for a new seat
+	Room               string        // room to join on connect, empty to stay in the lobby
+	Watch              string        // room to watch as a spectator on connect
+	QuickPlay          bool          // join the quick-play queue on connect
+	PlayDifficulty     string        // quick-play preferred difficulty, empty for the server default
+	PlayPlayers        int           // quick-play preferred player count, 0 for the server default
+	Reconnect          bool          // reconnect automatically when the server drops
+	MaxRetries         int           // reconnect attempts in a row before giving up, 0 retries forever
+	InitialBackoff     time.Duration // delay before the first retry, doubled on every attempt
+	MaxBackoff         time.Duration // upper bound of the retry delay
+}
+
+// StartClient connects to server and runs the client loop.
+// When reconnecting is enabled, a dropped connection is retried with exponential
+// backoff and the seat is resumed with the session token issued by the server.
+func StartClient(cfg ClientConfig) error {
+	inputCh := make(chan string)
+
+	// Stdin reader, shared by all connections
+	go func() {
+		reader := bufio.NewReader(os.Stdin)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				close(inputCh)
+				return
+			}
+			inputCh <- strings.TrimSpace(line)
+		}
+	}()
+
+	tlsCfg, err := clientTLSConfig(cfg)
 	if err != nil {
-		return fmt.Errorf("error connecting to server: %w", err)
+		return err
 	}
+
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	token := cfg.Token
+	watching := "" // the room watched as a spectator, watched again after a reconnect
+	attempt := 0
+
+	for {
+		conn, err := dial(cfg.Address, tlsCfg)
+		if err == nil {
+			var joined bool
+			joined, err = runSession(conn, handshake(cfg, token, watching), &token, &watching, inputCh)
+			if err == nil {
+				return nil
+			}
+			if !joined {
+				return fmt.Errorf("%w before a seat was assigned or a room watched", err)
+			}
+			attempt = 0
+		} else {
+			err = fmt.Errorf("error connecting to server: %w", err)
+		}
+
+		if !cfg.Reconnect {
+			return err
+		}
+		attempt++
+		if cfg.MaxRetries > 0 && attempt > cfg.MaxRetries {
+			return fmt.Errorf("giving up after %d reconnect attempts: %w", cfg.MaxRetries, err)
+		}
+
+		delay := backoffDelay(attempt, cfg.InitialBackoff, cfg.MaxBackoff, rng)
+		fmt.Printf("Connection lost (%v). Reconnecting in %s (attempt %d)...\n", err, delay.Round(time.Millisecond), attempt)
+		if !waitForRetry(delay, inputCh) {
+			fmt.Println("Exiting game...")
+			return nil
+		}
+	}
+}
+
+// runSession plays over a single connection. It returns nil when the player exits,
+// and reports whether the server assigned a seat, or a room to watch, before the connection ended.
+func runSession(conn net.Conn, hello string, token, watching *string, inputCh <-chan string) (bool, error) {
 	defer conn.Close()
 
-	fmt.Println("Connected to Code Breaker server. Waiting for game updates...")
+	if *token != "" {
+		fmt.Println("Connected to Code Breaker server. Resuming session...")
+	} else {
+		fmt.Println("Connected to Code Breaker server. Waiting for game updates...")
+	}
 
-	decoder := json.NewDecoder(conn)
+	if _, err := conn.Write([]byte(hello)); err != nil {
+		return false, fmt.Errorf("error sending handshake: %w", err)
+	}
 
+	decoder := json.NewDecoder(conn)
 	serverMsgCh := make(chan game.Message)
-	inputCh := make(chan string)
+	done := make(chan struct{})
+	defer close(done)
 
 	// Server reader
 	go func() {
+		defer close(serverMsgCh)
 		for {
 			var msg game.Message
 			if err := decoder.Decode(&msg); err != nil {
-				close(serverMsgCh)
 				return
 			}
-			serverMsgCh <- msg
-		}
-	}()
-
-	// Stdin reader
-	go func() {
-		reader := bufio.NewReader(os.Stdin)
-		for {
-			line, err := reader.ReadString('\n')
-			if err != nil {
-				close(inputCh)
+			select {
+			case serverMsgCh <- msg:
+			case <-done:
 				return
 			}
-			inputCh <- strings.TrimSpace(line)
 		}
 	}()
 
+	joined := false
 	isMyTurn := false
+	saveFile := ""   // where the notation asked for by /save goes
+	commitment := "" // to the secret of the current game
 	lastPrinted := game.Message{}
 
 	for {
 		select {
 		case msg, ok := <-serverMsgCh:
 			if !ok {
-				return fmt.Errorf("server disconnected")
+				return joined, errServerDisconnected
+			}
+			if msg.Type == game.NOTATION && saveFile != "" {
+				// every reply to /notation is a NOTATION message, with or without a game
+				if msg.Notation != "" {
+					saveNotation(saveFile, msg.Notation)
+				} else {
+					fmt.Printf("Nothing saved to %s: %s", saveFile, msg.Text)
+				}
+				saveFile = ""
+				continue
 			}
 			// Always print server text
 			if msg.Type != lastPrinted.Type || msg.Text != lastPrinted.Text {
@@ -67,19 +170,55 @@ func StartClient(address string) error {
 			}
 
 			switch msg.Type {
+			case game.SESSION:
+				joined = true
+				*token = msg.Token
+				*watching = ""
+			case game.WATCHING:
+				joined = true
+				*watching = msg.Room
 			case game.TURN:
 				isMyTurn = true
 				fmt.Print("Your guess: ")
 			case game.RECOVERY:
 				isMyTurn = true
 				fmt.Print("Recovery guess allowed: ")
-			case game.WAIT, game.TIMEOUT, game.RESULT, game.WIN, game.NEWGAME, game.INFO:
+			case game.NEWGAME:
+				isMyTurn = false
+				if msg.Commitment != "" {
+					commitment = msg.Commitment
+				}
+			case game.SNAPSHOT:
+				isMyTurn = false
+				if msg.Snapshot != nil {
+					commitment = msg.Snapshot.Commitment
+				}
+			case game.WIN:
+				isMyTurn = false
+				fmt.Print(checkCommitment(commitment, msg))
+			case game.WAIT, game.TIMEOUT, game.RESULT, game.INFO, game.SECRET:
 				isMyTurn = false
 			}
 
 		case guess, ok := <-inputCh:
 			if !ok {
-				return nil
+				return joined, nil
+			}
+			if cmd, arg, _ := strings.Cut(guess, " "); cmd == cmdSave {
+				file, last, _ := strings.Cut(strings.TrimSpace(arg), " ")
+				if file == "" {
+					fmt.Println("Usage: " + cmdSave + " <file> [last]")
+					continue
+				}
+				saveFile = file
+				guess = strings.TrimSpace(game.CmdNotation + " " + last)
+			}
+			if strings.HasPrefix(guess, "/") {
+				// commands are sent at any time
+				if _, err := conn.Write([]byte(guess + "\n")); err != nil {
+					return joined, err
+				}
+				continue
 			}
 			if !isMyTurn {
 				// ignore when not turn
@@ -88,12 +227,124 @@ func StartClient(address string) error {
 			isMyTurn = false
 			if guess == "exit" {
 				fmt.Println("Exiting game...")
-				return nil
+				return joined, nil
 			}
-			_, err = conn.Write([]byte(guess + "\n"))
-			if err != nil {
-				return err
+			if _, err := conn.Write([]byte(guess + "\n")); err != nil {
+				return joined, err
+			}
+		}
+	}
+}
+
+// checkCommitment checks the secret and salt revealed by a WIN message against the commitment
+// published when the game started, and returns what to tell the player.
+func checkCommitment(commitment string, win game.Message) string {
+	switch {
+	case commitment == "" || win.Salt == "" || win.CodeLength == 0:
+		return "The secret could not be verified: the server sent no commitment.\n"
+	case !game.VerifyCommitment(commitment, win.Salt, win.Secret, win.CodeLength):
+		return "Warning: the revealed secret does not match the commitment published when the game started. " +
+			"The secret may have been changed during the game.\n"
+	}
+	return "Secret verified against the commitment published when the game started.\n"
+}
+
+// saveNotation writes a game in notation to file and warns if it is inconsistent.
+func saveNotation(file, notation string) {
+	if err := os.WriteFile(file, []byte(notation), 0o644); err != nil {
+		fmt.Println("Error saving the game:", err)
+		return
+	}
+	fmt.Println("Game saved to " + file)
+	games, err := game.ParseNotation(strings.NewReader(notation))
+	if err == nil {
+		err = games[0].Validate()
+	}
+	if err != nil {
+		fmt.Printf("Warning: the saved game is inconsistent: %v\n", err)
+	}
+}
+
+// handshake returns the lines sent on connect: the session to resume, or the
+// nickname followed by the room or queue to join, or the room watched before.
+func handshake(cfg ClientConfig, token, watching string) string {
+	prefs := fmt.Sprintf(" color=%t", cfg.Color)
+	if cfg.Locale != "" {
+		prefs += " locale=" + cfg.Locale
+	}
+	if token != "" {
+		return game.CmdResume + " " + token + prefs + "\n"
+	}
+
+	hello := game.CmdHello + prefs + "\n"
+	switch {
+	case cfg.Password != "" && cfg.Register:
+		hello = game.CmdRegister + " " + cfg.Name + " " + cfg.Password + prefs + "\n"
+	case cfg.Password != "":
+		hello = game.CmdLogin + " " + cfg.Name + " " + cfg.Password + prefs + "\n"
+	case cfg.Name != "":
+		hello = game.CmdHello + " " + cfg.Name + prefs + "\n"
+	}
+	join := joinCommand(cfg)
+	if watching != "" {
+		// spectators have no seat to resume, they watch again
+		join = game.CmdWatch + " " + watching
+	}
+	if join != "" {
+		hello += join + "\n"
+	}
+	return hello
+}
+
+// joinCommand is the lobby command sent after connecting without a session, if any.
+func joinCommand(cfg ClientConfig) string {
+	switch {
+	case cfg.Room != "":
+		return game.CmdJoin + " " + cfg.Room
+	case cfg.Watch != "":
+		return game.CmdWatch + " " + cfg.Watch
+	case cfg.QuickPlay:
+		cmd := game.CmdPlay
+		if cfg.PlayDifficulty != "" {
+			cmd += " difficulty=" + cfg.PlayDifficulty
+		}
+		if cfg.PlayPlayers > 0 {
+			cmd += fmt.Sprintf(" players=%d", cfg.PlayPlayers)
+		}
+		return cmd
+	}
+	return ""
+}
+
+// waitForRetry sleeps for delay; it returns false if the player exits meanwhile.
+func waitForRetry(delay time.Duration, inputCh <-chan string) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			return true
+		case line, ok := <-inputCh:
+			if !ok || line == "exit" {
+				return false
 			}
 		}
 	}
 }
+
+// backoffDelay returns the delay before reconnect attempt n (starting at 1):
+// initial doubled per attempt, capped at max, with random jitter over its upper half.
+func backoffDelay(attempt int, initial, max time.Duration, rng *rand.Rand) time.Duration {
+	delay := initial
+	for i := 1; i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+	return half + time.Duration(rng.Int63n(int64(half)+1))
+}
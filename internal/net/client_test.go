@@ -0,0 +1,70 @@
+package netpkg
+
+import (
+	"bufio"
+	"encoding/json"
+	"math/rand"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"code_breaker/internal/game"
+)
+
+func TestBackoffDelay_GrowsWithJitterAndCaps(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	initial := 100 * time.Millisecond
+	max := time.Second
+
+	for i := 0; i < 100; i++ {
+		d1 := backoffDelay(1, initial, max, rng)
+		assert.GreaterOrEqual(t, d1, 50*time.Millisecond)
+		assert.LessOrEqual(t, d1, 100*time.Millisecond)
+
+		d3 := backoffDelay(3, initial, max, rng)
+		assert.GreaterOrEqual(t, d3, 200*time.Millisecond)
+		assert.LessOrEqual(t, d3, 400*time.Millisecond)
+
+		d20 := backoffDelay(20, initial, max, rng)
+		assert.GreaterOrEqual(t, d20, 500*time.Millisecond)
+		assert.LessOrEqual(t, d20, max)
+	}
+}
+
+func TestRunSession_SpectatorsWatchAgainAfterAReconnect(t *testing.T) {
+	cfg := ClientConfig{Name: "carol", Watch: "main"}
+	client, server := net.Pipe()
+	go func() {
+		_, _ = bufio.NewReader(server).ReadString('\n')
+		_ = json.NewEncoder(server).Encode(game.Message{Type: game.WATCHING, Text: "You are watching room duel.\n", Room: "duel"})
+		_ = server.Close()
+	}()
+
+	token, watching := "", ""
+	joined, err := runSession(client, handshake(cfg, token, watching), &token, &watching, nil)
+	require.ErrorIs(t, err, errServerDisconnected)
+	assert.True(t, joined)
+	assert.Equal(t, "duel", watching)
+	assert.Equal(t, game.CmdHello+" carol color=false\n"+game.CmdWatch+" duel\n", handshake(cfg, token, watching))
+}
+
+func TestCheckCommitment_WarnsWhenTheSecretChanged(t *testing.T) {
+	commitment := game.CommitSecret(4213, 4, "salt")
+	win := game.Message{Type: game.WIN, Secret: 4213, Salt: "salt", CodeLength: 4}
+	assert.Contains(t, checkCommitment(commitment, win), "Secret verified")
+
+	win.Secret = 4231
+	assert.Contains(t, checkCommitment(commitment, win), "Warning: the revealed secret does not match the commitment")
+	win.Secret, win.Salt = 4213, "pepper"
+	assert.Contains(t, checkCommitment(commitment, win), "Warning")
+	assert.Contains(t, checkCommitment("", win), "could not be verified")
+
+	// the code length comes with the message, so a secret with a leading zero verifies too
+	win = game.Message{Type: game.WIN, Secret: 123, Salt: "salt", CodeLength: 4}
+	assert.Contains(t, checkCommitment(game.CommitSecret(123, 4, "salt"), win), "Secret verified")
+	win.CodeLength = 0
+	assert.Contains(t, checkCommitment(game.CommitSecret(123, 4, "salt"), win), "could not be verified")
+}
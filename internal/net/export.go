@@ -0,0 +1,312 @@
+package netpkg
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"code_breaker/internal/game"
+)
+
+// apiExportPath serves the game records for analysis elsewhere (admin):
+//
+//	GET /api/export?data=games|guesses|analytics&format=csv|json|notation&room=&difficulty=&codeLength=&since=&until=
+//
+// data defaults to games and format to csv; the notation format only has games.
+// The filters are those of parseRecordFilter.
+const apiExportPath = "/api/export"
+
+// The data sets of an export.
+const (
+	exportGames     = "games"     // one row per game
+	exportGuesses   = "guesses"   // one row per guess
+	exportAnalytics = "analytics" // the analytics of each room, one row per figure in CSV
+)
+
+// The formats of an export.
+const (
+	exportCSV      = "csv"
+	exportJSON     = "json"
+	exportNotation = "notation" // the game notation, games only
+)
+
+// exportedGuess is a guess with the game it belongs to.
+type exportedGuess struct {
+	GameID int    `json:"gameId"`
+	Room   string `json:"room"`
+	Game   int    `json:"game"`
+	Number int    `json:"number"` // 1 for the first guess of the game
+	game.GuessRecord
+}
+
+func validateExport(data, format string) error {
+	switch data {
+	case exportGames, exportGuesses, exportAnalytics:
+	default:
+		return fmt.Errorf("invalid data %q, expected %s, %s or %s", data, exportGames, exportGuesses, exportAnalytics)
+	}
+	switch format {
+	case exportCSV, exportJSON:
+	case exportNotation:
+		if data != exportGames {
+			return fmt.Errorf("format %s only exports %s", exportNotation, exportGames)
+		}
+	default:
+		return fmt.Errorf("invalid format %q, expected %s, %s or %s", format, exportCSV, exportJSON, exportNotation)
+	}
+	return nil
+}
+
+// writeExport writes a data set of the records in format.
+func writeExport(w io.Writer, records []GameRecord, data, format string) error {
+	if err := validateExport(data, format); err != nil {
+		return err
+	}
+	if format == exportNotation {
+		games := make([]game.GameNotation, 0, len(records))
+		for _, rec := range records {
+			games = append(games, recordNotation(rec))
+		}
+		_, err := io.WriteString(w, game.FormatNotations(games))
+		return err
+	}
+	if format == exportJSON {
+		var v interface{}
+		switch data {
+		case exportGames:
+			v = append([]GameRecord{}, records...)
+		case exportGuesses:
+			v = exportedGuesses(records)
+		case exportAnalytics:
+			v = analyticsByRoom(records)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	cw := csv.NewWriter(w)
+	switch data {
+	case exportGames:
+		writeGamesCSV(cw, records)
+	case exportGuesses:
+		writeGuessesCSV(cw, records)
+	case exportAnalytics:
+		writeAnalyticsCSV(cw, analyticsByRoom(records))
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func exportedGuesses(records []GameRecord) []exportedGuess {
+	guesses := make([]exportedGuess, 0)
+	for _, rec := range records {
+		for i, g := range rec.Guesses {
+			guesses = append(guesses, exportedGuess{GameID: rec.ID, Room: rec.Room, Game: rec.Game, Number: i + 1, GuessRecord: g})
+		}
+	}
+	return guesses
+}
+
+func analyticsByRoom(records []GameRecord) map[string]Analytics {
+	byRoom := make(map[string]*Analytics)
+	for _, rec := range records {
+		if byRoom[rec.Room] == nil {
+			byRoom[rec.Room] = newAnalytics()
+		}
+		byRoom[rec.Room].add(rec)
+	}
+	analytics := make(map[string]Analytics, len(byRoom))
+	for room, a := range byRoom {
+		analytics[room] = *a
+	}
+	return analytics
+}
+
+func writeGamesCSV(w *csv.Writer, records []GameRecord) {
+	_ = w.Write([]string{"id", "room", "game", "started", "ended", "duration_seconds",
+		"max_players", "code_length", "difficulty", "turn_time_seconds",
+		"players", "bots", "winner", "secret", "guesses", "turns", "timeouts", "recoveries"})
+	for _, rec := range records {
+		var players, bots []string
+		for _, p := range rec.Players {
+			players = append(players, p.Name)
+			if p.Bot {
+				bots = append(bots, p.Name)
+			}
+		}
+		_ = w.Write([]string{
+			strconv.Itoa(rec.ID), rec.Room, strconv.Itoa(rec.Game),
+			rec.Started.Format(time.RFC3339), rec.Ended.Format(time.RFC3339),
+			strconv.FormatFloat(rec.DurationSeconds, 'f', 3, 64),
+			strconv.Itoa(rec.Config.MaxPlayers), strconv.Itoa(rec.Config.CodeLength),
+			string(rec.Config.Difficulty), strconv.Itoa(rec.Config.TurnTimeSeconds),
+			strings.Join(players, ";"), strings.Join(bots, ";"), rec.Winner,
+			formatCode(rec.Secret, rec.Config.CodeLength), strconv.Itoa(len(rec.Guesses)),
+			strconv.Itoa(rec.Turns), strconv.Itoa(rec.Timeouts), strconv.Itoa(rec.Recoveries),
+		})
+	}
+}
+
+func writeGuessesCSV(w *csv.Writer, records []GameRecord) {
+	_ = w.Write([]string{"game_id", "room", "game", "number", "player", "player_name",
+		"guess", "correct_place", "wrong_place", "hint"})
+	for _, rec := range records {
+		for i, g := range rec.Guesses {
+			_ = w.Write([]string{
+				strconv.Itoa(rec.ID), rec.Room, strconv.Itoa(rec.Game), strconv.Itoa(i + 1),
+				strconv.Itoa(g.Player), g.PlayerName, formatCode(g.Guess, rec.Config.CodeLength),
+				strconv.Itoa(g.CorrectPlace), strconv.Itoa(g.WrongPlace), g.Hint,
+			})
+		}
+	}
+}
+
+// writeAnalyticsCSV writes the analytics in long form, one figure per row, sorted by room.
+func writeAnalyticsCSV(w *csv.Writer, byRoom map[string]Analytics) {
+	_ = w.Write([]string{"room", "metric", "key", "value"})
+	rooms := make([]string, 0, len(byRoom))
+	for room := range byRoom {
+		rooms = append(rooms, room)
+	}
+	sort.Strings(rooms)
+
+	for _, room := range rooms {
+		a := byRoom[room]
+		_ = w.Write([]string{room, "games_played", "", strconv.Itoa(a.GamesPlayed)})
+		for _, name := range sortedNames(a.WinsByPlayer) {
+			_ = w.Write([]string{room, "wins", name, strconv.Itoa(a.WinsByPlayer[name])})
+		}
+		for _, name := range sortedNames(a.LossesByPlayer) {
+			_ = w.Write([]string{room, "losses", name, strconv.Itoa(a.LossesByPlayer[name])})
+		}
+		secrets := make([]int, 0, len(a.GuessesUntilWin))
+		for secret := range a.GuessesUntilWin {
+			secrets = append(secrets, secret)
+		}
+		sort.Ints(secrets)
+		for _, secret := range secrets {
+			for _, guesses := range a.GuessesUntilWin[secret] {
+				_ = w.Write([]string{room, "guesses_until_win", strconv.Itoa(secret), strconv.Itoa(guesses)})
+			}
+		}
+	}
+}
+
+// sortedNames returns the player names of a count by player, sorted.
+func sortedNames(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatCode writes a code with its leading zeros.
+func formatCode(code, length int) string {
+	return fmt.Sprintf("%0*d", length, code)
+}
+
+func (h *apiHandler) serveExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	if !h.authorized(w, r) {
+		return
+	}
+	query := r.URL.Query()
+	data, format := queryDefault(query, "data", exportGames), queryDefault(query, "format", exportCSV)
+	filter, err := parseRecordFilter(query)
+	if err == nil {
+		err = validateExport(data, format)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	contentType, ext := "text/csv; charset=utf-8", "."+format
+	switch format {
+	case exportJSON:
+		contentType = "application/json"
+	case exportNotation:
+		contentType, ext = "text/plain; charset=utf-8", game.NotationExtension
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="codebreaker-%s%s"`, data, ext))
+	records := filterRecords(h.lobby.games.Records(filter.Room), filter)
+	if err := writeExport(w, records, data, format); err != nil {
+		serverLog.Warn("error writing export", "err", err)
+	}
+}
+
+func queryDefault(query url.Values, key, defaultVal string) string {
+	if val := query.Get(key); val != "" {
+		return val
+	}
+	return defaultVal
+}
+
+// RunExport implements the export command: it reads the game records file and writes
+// the selected data to stdout, or to the file given with -o.
+func RunExport(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+	file := flags.String("file", os.Getenv("ANALYTICS_FILE"), "game records file (env ANALYTICS_FILE)")
+	data := flags.String("data", exportGames, "data to export: games, guesses or analytics")
+	format := flags.String("format", exportCSV, "output format: csv, json or notation (games only)")
+	out := flags.String("o", "", "output file instead of stdout")
+	room := flags.String("room", "", "only games played in this room")
+	difficulty := flags.String("difficulty", "", "only games with this difficulty")
+	codeLength := flags.String("code-length", "", "only games with this code length")
+	since := flags.String("since", "", "only games that ended at or after this RFC 3339 time or date")
+	until := flags.String("until", "", "only games that ended before this RFC 3339 time, or on or before this date")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	}
+	if *file == "" {
+		return errors.New("no game records file, set -file or ANALYTICS_FILE")
+	}
+	filter, err := parseRecordFilter(url.Values{
+		"room": {*room}, "difficulty": {*difficulty}, "codeLength": {*codeLength}, "since": {*since}, "until": {*until},
+	})
+	if err != nil {
+		return err
+	}
+	if err := validateExport(*data, *format); err != nil {
+		return err
+	}
+
+	records, err := LoadGameRecords(*file)
+	if err != nil {
+		return err
+	}
+	records = filterRecords(records, filter)
+	if *out == "" {
+		return writeExport(stdout, records, *data, *format)
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := writeExport(f, records, *data, *format); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
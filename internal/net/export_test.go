@@ -0,0 +1,163 @@
+package netpkg
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"code_breaker/internal/game"
+)
+
+func exportTestRecords() []GameRecord {
+	cfg := RecordedConfig{MaxPlayers: 2, CodeLength: 4, Difficulty: game.DifficultyEasy, TurnTimeSeconds: 30}
+	start := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	return []GameRecord{
+		{ID: 1, Room: "main", Game: 1, Config: cfg, Secret: 123, Winner: "alice",
+			Players: []RecordedPlayer{{Name: "alice"}, {Name: "bot-1", Bot: true}},
+			Guesses: []game.GuessRecord{
+				{Player: 1, PlayerName: "alice", Guess: 1111, CorrectPlace: 1, Hint: "a, b"},
+				{Player: 2, PlayerName: "bot-1", Guess: 12, CorrectPlace: 2, WrongPlace: 1},
+				{Player: 1, PlayerName: "alice", Guess: 123, CorrectPlace: 4},
+			},
+			Started: start, Ended: start.Add(90 * time.Second), DurationSeconds: 90, Turns: 2},
+		{ID: 2, Room: "duel", Game: 1, Config: cfg, Secret: 4321,
+			Players: []RecordedPlayer{{Name: "bob"}},
+			Started: start.AddDate(0, 0, 1), Ended: start.AddDate(0, 0, 1).Add(time.Minute), DurationSeconds: 60, Turns: 1, Timeouts: 1},
+	}
+}
+
+func readCSV(t *testing.T, data string) [][]string {
+	rows, err := csv.NewReader(strings.NewReader(data)).ReadAll()
+	require.NoError(t, err)
+	return rows
+}
+
+func TestWriteExport_CSV(t *testing.T) {
+	var b bytes.Buffer
+	require.NoError(t, writeExport(&b, exportTestRecords(), exportGames, exportCSV))
+	rows := readCSV(t, b.String())
+	require.Len(t, rows, 3)
+	assert.Equal(t, []string{"1", "main", "1", "2026-03-01T10:00:00Z", "2026-03-01T10:01:30Z", "90.000",
+		"2", "4", "easy", "30", "alice;bot-1", "bot-1", "alice", "0123", "3", "2", "0", "0"}, rows[1])
+	assert.Equal(t, "", rows[2][12]) // no winner
+
+	b.Reset()
+	require.NoError(t, writeExport(&b, exportTestRecords(), exportGuesses, exportCSV))
+	rows = readCSV(t, b.String())
+	require.Len(t, rows, 4)
+	assert.Equal(t, []string{"1", "main", "1", "2", "2", "bot-1", "0012", "2", "1", ""}, rows[2])
+	assert.Equal(t, "a, b", rows[1][9])
+
+	b.Reset()
+	require.NoError(t, writeExport(&b, exportTestRecords(), exportAnalytics, exportCSV))
+	assert.Equal(t, [][]string{
+		{"room", "metric", "key", "value"},
+		{"duel", "games_played", "", "0"},
+		{"main", "games_played", "", "1"},
+		{"main", "wins", "alice", "1"},
+		{"main", "losses", "bot-1", "1"},
+		{"main", "guesses_until_win", "123", "3"},
+	}, readCSV(t, b.String()))
+
+	assert.Error(t, writeExport(&b, nil, "players", exportCSV))
+	assert.Error(t, writeExport(&b, nil, exportGames, "xlsx"))
+}
+
+func TestWriteExport_JSON(t *testing.T) {
+	var b bytes.Buffer
+	require.NoError(t, writeExport(&b, exportTestRecords(), exportGames, exportJSON))
+	var games []GameRecord
+	require.NoError(t, json.Unmarshal(b.Bytes(), &games))
+	assert.Equal(t, exportTestRecords(), games)
+
+	b.Reset()
+	require.NoError(t, writeExport(&b, exportTestRecords(), exportGuesses, exportJSON))
+	var guesses []exportedGuess
+	require.NoError(t, json.Unmarshal(b.Bytes(), &guesses))
+	require.Len(t, guesses, 3)
+	assert.Equal(t, 3, guesses[2].Number)
+	assert.Equal(t, 123, guesses[2].Guess)
+
+	b.Reset()
+	require.NoError(t, writeExport(&b, nil, exportGames, exportJSON))
+	assert.Equal(t, "[]\n", b.String())
+}
+
+func TestAPI_ExportRequiresAdminAndFilters(t *testing.T) {
+	store := newMemoryGameStore()
+	for _, rec := range exportTestRecords() {
+		rec := rec
+		require.NoError(t, store.Append(&rec))
+	}
+	lobby := NewLobby(testConfig(1), game.MatchmakingConfig{WaitSeconds: 1}, nil, store)
+	handler := newTestHTTPHandler(lobby, game.ServerConfig{AdminToken: testAdminToken})
+
+	get := func(query, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, apiExportPath+query, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, get("", "").Code)
+
+	resp := get("?until=2026-03-01", testAdminToken)
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", resp.Header().Get("Content-Type"))
+	assert.Contains(t, resp.Header().Get("Content-Disposition"), "codebreaker-games.csv")
+	rows := readCSV(t, resp.Body.String())
+	require.Len(t, rows, 2)
+	assert.Equal(t, "main", rows[1][1])
+
+	resp = get("?data=analytics&format=json&room=duel", testAdminToken)
+	require.Equal(t, http.StatusOK, resp.Code)
+	var analytics map[string]Analytics
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &analytics))
+	require.Len(t, analytics, 1)
+	assert.Contains(t, analytics, "duel")
+
+	assert.Equal(t, http.StatusBadRequest, get("?format=xml", testAdminToken).Code)
+	assert.Equal(t, http.StatusBadRequest, get("?since=soon", testAdminToken).Code)
+}
+
+func TestRunExport_ReadsTheRecordsFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "games.jsonl")
+	store, err := OpenGameStore(path)
+	require.NoError(t, err)
+	for _, rec := range exportTestRecords() {
+		rec := rec
+		require.NoError(t, store.Append(&rec))
+	}
+	require.NoError(t, store.Close())
+
+	var stdout bytes.Buffer
+	require.NoError(t, RunExport([]string{"-file", path, "-data", "guesses", "-room", "main", "-since", "2026-03-01"}, &stdout))
+	assert.Len(t, readCSV(t, stdout.String()), 4)
+
+	out := filepath.Join(dir, "games.json")
+	require.NoError(t, RunExport([]string{"-file", path, "-format", "json", "-o", out, "-until", "2026-03-01T12:00:00Z"}, &stdout))
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	var games []GameRecord
+	require.NoError(t, json.Unmarshal(data, &games))
+	require.Len(t, games, 1)
+	assert.Equal(t, "main", games[0].Room)
+
+	assert.Error(t, RunExport([]string{"-file", path, "-data", "players"}, &stdout))
+	assert.Error(t, RunExport([]string{"-file", path, "-difficulty", "extreme"}, &stdout))
+	assert.Error(t, RunExport([]string{"-file", path, "extra"}, &stdout))
+}
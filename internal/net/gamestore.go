@@ -0,0 +1,252 @@
+package netpkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"code_breaker/internal/game"
+)
+
+// GameRecord is a game as kept in the game store once it is over.
+type GameRecord struct {
+	ID              int                `json:"id"`   // assigned by the store, in the order games end
+	Room            string             `json:"room"` // the room the game was played in
+	Game            int                `json:"game"` // number of the game in its room
+	Config          RecordedConfig     `json:"config"`
+	Players         []RecordedPlayer   `json:"players"` // seated when the game started, in seat order, kicked players included
+	Secret          int                `json:"secret"`
+	Salt            string             `json:"salt,omitempty"` // of the commitment to the secret published when the game started
+	Guesses         []game.GuessRecord `json:"guesses"`
+	Winner          string             `json:"winner,omitempty"` // empty if the room closed before anyone won
+	Started         time.Time          `json:"started"`
+	Ended           time.Time          `json:"ended"`
+	DurationSeconds float64            `json:"durationSeconds"`
+	Turns           int                `json:"turns"` // turns human players took, whether they guessed or timed out
+	Timeouts        int                `json:"timeouts"`
+	Recoveries      int                `json:"recoveries"`          // times every player timed out in a row
+	Scores          map[string]int     `json:"scores,omitempty"`    // scoring mode: the points of each player in the game
+	MatchGame       int                `json:"matchGame,omitempty"` // scoring mode: number of the game in its match
+}
+
+// RecordedConfig holds the settings a recorded game was played with.
+type RecordedConfig struct {
+	MaxPlayers      int             `json:"maxPlayers"`
+	CodeLength      int             `json:"codeLength"`
+	Difficulty      game.Difficulty `json:"difficulty"`
+	TurnTimeSeconds int             `json:"turnTimeSeconds"`
+}
+
+func recordedConfig(cfg game.Config) *RecordedConfig {
+	return &RecordedConfig{
+		MaxPlayers:      cfg.MaxPlayers,
+		CodeLength:      cfg.CodeLength,
+		Difficulty:      cfg.Difficulty,
+		TurnTimeSeconds: cfg.TurnTimeSeconds,
+	}
+}
+
+// RecordedPlayer is a seat of a recorded game.
+type RecordedPlayer struct {
+	Name string `json:"name"`
+	Bot  bool   `json:"bot,omitempty"`
+}
+
+// RecordFilter selects game records; zero fields match everything.
+type RecordFilter struct {
+	Room       string
+	Difficulty game.Difficulty
+	CodeLength int
+	Since      time.Time // games that ended at or after this time
+	Until      time.Time // games that ended before this time
+}
+
+func (f RecordFilter) match(rec GameRecord) bool {
+	return (f.Room == "" || rec.Room == f.Room) &&
+		(f.Difficulty == "" || rec.Config.Difficulty == f.Difficulty) &&
+		(f.CodeLength == 0 || rec.Config.CodeLength == f.CodeLength) &&
+		(f.Since.IsZero() || !rec.Ended.Before(f.Since)) &&
+		(f.Until.IsZero() || rec.Ended.Before(f.Until))
+}
+
+// filterRecords returns the records that match filter.
+func filterRecords(records []GameRecord, filter RecordFilter) []GameRecord {
+	var matched []GameRecord
+	for _, rec := range records {
+		if filter.match(rec) {
+			matched = append(matched, rec)
+		}
+	}
+	return matched
+}
+
+// parseRecordFilter reads a filter from the query parameters room, difficulty, codeLength, since and until.
+// The times are RFC 3339 or dates; until a date includes the whole day.
+func parseRecordFilter(query url.Values) (RecordFilter, error) {
+	filter := RecordFilter{Room: query.Get("room")}
+	if val := query.Get("difficulty"); val != "" {
+		d, ok := game.ParseDifficulty(val)
+		if !ok {
+			return filter, fmt.Errorf("invalid difficulty %q", val)
+		}
+		filter.Difficulty = d
+	}
+	if val := query.Get("codeLength"); val != "" {
+		n, err := strconv.Atoi(val)
+		if err != nil || n <= 0 {
+			return filter, fmt.Errorf("invalid code length %q", val)
+		}
+		filter.CodeLength = n
+	}
+	var err error
+	if filter.Since, err = parseRecordTime(query.Get("since"), false); err != nil {
+		return filter, err
+	}
+	if filter.Until, err = parseRecordTime(query.Get("until"), true); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+// parseRecordTime parses an RFC 3339 time or a date in UTC, taking the end of the day for an upper bound.
+func parseRecordTime(val string, upper bool) (time.Time, error) {
+	if val == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, val); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", val)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, expected RFC 3339 or YYYY-MM-DD", val)
+	}
+	if upper {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// GameStore keeps the records of all games. With a file, each record is appended to it
+// as a line of JSON as soon as its game is over, and the records are read back on startup.
+type GameStore struct {
+	mu      sync.Mutex
+	file    *os.File // nil keeps the records in memory only
+	records []GameRecord
+}
+
+// newMemoryGameStore creates a store that forgets its records when the server stops.
+func newMemoryGameStore() *GameStore {
+	return &GameStore{}
+}
+
+// OpenGameStore loads the game records at path, creating the file if it is missing.
+// A last line cut short by a crash is dropped.
+func OpenGameStore(path string) (*GameStore, error) {
+	records, valid, err := readGameRecords(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &GameStore{records: records}
+	if s.file, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600); err != nil {
+		return nil, err
+	}
+	if err := s.file.Truncate(int64(valid)); err != nil {
+		_ = s.file.Close()
+		return nil, err
+	}
+	if _, err := s.file.Seek(int64(valid), 0); err != nil {
+		_ = s.file.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// LoadGameRecords reads the game records at path without changing the file, so a running server can keep writing to it.
+func LoadGameRecords(path string) ([]GameRecord, error) {
+	records, _, err := readGameRecords(path)
+	return records, err
+}
+
+// readGameRecords parses the records at path and returns them with the length of the complete lines.
+// A missing file has no records.
+func readGameRecords(path string) ([]GameRecord, int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, 0, err
+	}
+
+	var records []GameRecord
+	valid := 0
+	for line := 1; valid < len(data); line++ {
+		end := bytes.IndexByte(data[valid:], '\n')
+		if end < 0 {
+			// records are written a whole line at a time, so the write was interrupted
+			serverLog.Warn("dropping an incomplete game record", "file", path, "line", line)
+			break
+		}
+		if chunk := bytes.TrimSpace(data[valid : valid+end]); len(chunk) > 0 {
+			var rec GameRecord
+			if err := json.Unmarshal(chunk, &rec); err != nil {
+				return nil, 0, fmt.Errorf("invalid game record in %s, line %d: %w", path, line, err)
+			}
+			records = append(records, rec)
+		}
+		valid += end + 1
+	}
+	return records, valid, nil
+}
+
+// Append stores a record, giving it the next ID.
+func (s *GameStore) Append(rec *GameRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec.ID = 1
+	if n := len(s.records); n > 0 {
+		rec.ID = s.records[n-1].ID + 1
+	}
+	s.records = append(s.records, *rec)
+	if s.file == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("error saving game record: %w", err)
+	}
+	return s.file.Sync()
+}
+
+// Records returns the records of the games played in room, or of all games if room is empty, oldest first.
+func (s *GameStore) Records(room string) []GameRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var records []GameRecord
+	for _, rec := range s.records {
+		if room == "" || rec.Room == room {
+			records = append(records, rec)
+		}
+	}
+	return records
+}
+
+// Close closes the store's file; records appended afterwards are kept in memory only.
+func (s *GameStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
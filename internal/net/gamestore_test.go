@@ -0,0 +1,132 @@
+package netpkg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"code_breaker/internal/game"
+)
+
+// guessSecret sends the secret of room's current game as the player's guess.
+func guessSecret(t *testing.T, room *Room, player *testClient) {
+	var secret, length int
+	require.NoError(t, room.do(func() error {
+		secret, length = room.secret, room.cfg.CodeLength
+		return nil
+	}))
+	player.send(fmt.Sprintf("%0*d", length, secret))
+}
+
+func TestGameStore_AppendsAndReloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "games.jsonl")
+	store, err := OpenGameStore(path)
+	require.NoError(t, err)
+	require.NoError(t, store.Append(&GameRecord{Room: "a", Game: 1, Secret: 1234, Winner: "alice"}))
+	rec := GameRecord{Room: "b", Game: 1, Secret: 5678}
+	require.NoError(t, store.Append(&rec))
+	assert.Equal(t, 2, rec.ID)
+	require.NoError(t, store.Close())
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	store, err = OpenGameStore(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+	records := store.Records("")
+	require.Len(t, records, 2)
+	assert.Equal(t, GameRecord{ID: 1, Room: "a", Game: 1, Secret: 1234, Winner: "alice"}, records[0])
+	assert.Equal(t, []GameRecord{records[1]}, store.Records("b"))
+	assert.Empty(t, store.Records("c"))
+}
+
+func TestGameStore_DropsIncompleteLastRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "games.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte(`{"id":1,"room":"a"}`+"\n"+`{"id":2,"ro`), 0o600))
+
+	store, err := OpenGameStore(path)
+	require.NoError(t, err)
+	require.Len(t, store.Records(""), 1)
+	require.NoError(t, store.Append(&GameRecord{Room: "a"}))
+	require.NoError(t, store.Close())
+
+	store, err = OpenGameStore(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+	records := store.Records("")
+	require.Len(t, records, 2)
+	assert.Equal(t, 2, records[1].ID)
+
+	require.NoError(t, os.WriteFile(path, []byte("garbage\n"+`{"id":1}`+"\n"), 0o600))
+	_, err = OpenGameStore(path)
+	assert.ErrorContains(t, err, "line 1")
+}
+
+func TestAnalytics_KeepsEveryGameOfARepeatedSecret(t *testing.T) {
+	a := summarize([]GameRecord{
+		{Secret: 1234, Winner: "alice", Players: []RecordedPlayer{{Name: "alice"}, {Name: "bob"}}, Guesses: make([]game.GuessRecord, 3)},
+		{Secret: 1234, Winner: "bob", Players: []RecordedPlayer{{Name: "alice"}, {Name: "bob"}}, Guesses: make([]game.GuessRecord, 5)},
+		{Secret: 1234, Players: []RecordedPlayer{{Name: "alice"}}, Guesses: make([]game.GuessRecord, 9)}, // abandoned
+	})
+	assert.Equal(t, 2, a.GamesPlayed)
+	assert.Equal(t, map[string]int{"alice": 1, "bob": 1}, a.WinsByPlayer)
+	assert.Equal(t, map[string]int{"alice": 1, "bob": 1}, a.LossesByPlayer)
+	assert.Equal(t, map[int][]int{1234: {3, 5}}, a.GuessesUntilWin)
+}
+
+func TestRoom_AnalyticsSurviveRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "games.jsonl")
+	store, err := OpenGameStore(path)
+	require.NoError(t, err)
+	lobby := NewLobby(testConfig(1), game.MatchmakingConfig{WaitSeconds: 1}, nil, store)
+	room, err := lobby.CreateRoom(DefaultRoom, testConfig(1))
+	require.NoError(t, err)
+	addr := serveTestLobby(t, lobby)
+
+	player := dialPlayer(t, addr, "alice", joinMain)
+	player.expect(game.TURN)
+	player.send("0000")
+	player.expect(game.TURN)
+	guessSecret(t, room, player)
+	player.expect(game.WIN)
+
+	// the next game is abandoned when the room closes
+	player.expect(game.TURN)
+	player.send("0000")
+	player.expect(game.RESULT)
+	require.NoError(t, lobby.CloseRoom(DefaultRoom))
+	require.NoError(t, store.Close())
+
+	store, err = OpenGameStore(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+	records := store.Records(DefaultRoom)
+	require.Len(t, records, 2)
+	won := records[0]
+	assert.Equal(t, 1, won.Game)
+	assert.Equal(t, "alice", won.Winner)
+	assert.Equal(t, []RecordedPlayer{{Name: "alice"}}, won.Players)
+	assert.Equal(t, RecordedConfig{MaxPlayers: 1, CodeLength: 4, Difficulty: game.DifficultyMedium, TurnTimeSeconds: 30}, won.Config)
+	require.Len(t, won.Guesses, 2)
+	assert.Equal(t, won.Secret, won.Guesses[1].Guess)
+	assert.False(t, won.Ended.Before(won.Started))
+	assert.Equal(t, 2, won.Turns)
+	assert.Zero(t, won.Timeouts)
+	assert.Equal(t, "", records[1].Winner)
+	assert.Len(t, records[1].Guesses, 1)
+
+	restarted := NewLobby(testConfig(1), game.MatchmakingConfig{WaitSeconds: 1}, nil, store)
+	room, err = restarted.CreateRoom(DefaultRoom, testConfig(1))
+	require.NoError(t, err)
+	analytics, err := room.Analytics()
+	require.NoError(t, err)
+	assert.Equal(t, 1, analytics.GamesPlayed)
+	assert.Equal(t, map[string]int{"alice": 1}, analytics.WinsByPlayer)
+	assert.Equal(t, map[int][]int{won.Secret: {2}}, analytics.GuessesUntilWin)
+}
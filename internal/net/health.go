@@ -0,0 +1,115 @@
+package netpkg
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// Health check endpoints of the HTTP server. /healthz answers 200 as long as the server runs,
+// /readyz answers 503 while the server shuts down or a game listener is down.
+const (
+	healthzPath = "/healthz"
+	readyzPath  = "/readyz"
+)
+
+// HealthReport is the body of both health endpoints.
+type HealthReport struct {
+	Status    string           `json:"status"` // "ok", "draining" or "unavailable"
+	Draining  bool             `json:"draining"`
+	Listeners []ListenerStatus `json:"listeners"`
+	Capacity  Capacity         `json:"capacity"`
+}
+
+// ListenerStatus describes a game listener.
+type ListenerStatus struct {
+	Network string `json:"network"`
+	Address string `json:"address"`
+	Up      bool   `json:"up"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Capacity sums up the rooms of the lobby.
+type Capacity struct {
+	Rooms        int `json:"rooms"`
+	WaitingRooms int `json:"waitingRooms"` // rooms that have not started yet
+	FreeSeats    int `json:"freeSeats"`    // seats left in the waiting rooms
+	Players      int `json:"players"`
+	Spectators   int `json:"spectators"`
+}
+
+// Health keeps track of the game listeners for the health endpoints.
+type Health struct {
+	lobby *Lobby
+
+	mu        sync.Mutex
+	listeners []ListenerStatus
+}
+
+func newHealth(lobby *Lobby) *Health {
+	return &Health{lobby: lobby}
+}
+
+func (h *Health) addListener(listener net.Listener) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	addr := listener.Addr()
+	h.listeners = append(h.listeners, ListenerStatus{Network: addr.Network(), Address: addr.String(), Up: true})
+}
+
+// listenerDown records that the listener at addr stopped accepting connections because of err.
+func (h *Health) listenerDown(addr net.Addr, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i := range h.listeners {
+		if h.listeners[i].Network == addr.Network() && h.listeners[i].Address == addr.String() {
+			h.listeners[i].Up = false
+			if err != nil && !errors.Is(err, net.ErrClosed) {
+				h.listeners[i].Error = err.Error()
+			}
+		}
+	}
+}
+
+// Report describes the server; ready is false while it cannot take new players.
+func (h *Health) Report() (report HealthReport, ready bool) {
+	h.mu.Lock()
+	report.Listeners = append([]ListenerStatus{}, h.listeners...)
+	h.mu.Unlock()
+
+	report.Status, ready = "ok", true
+	for _, l := range report.Listeners {
+		if !l.Up {
+			report.Status, ready = "unavailable", false
+		}
+	}
+	if h.lobby.Draining() {
+		report.Status, report.Draining, ready = "draining", true, false
+	}
+
+	for _, room := range h.lobby.Rooms() {
+		report.Capacity.Rooms++
+		report.Capacity.Players += room.Players
+		report.Capacity.Spectators += room.Spectators
+		if !room.Started {
+			report.Capacity.WaitingRooms++
+			report.Capacity.FreeSeats += room.MaxPlayers - room.Players
+		}
+	}
+	return report, ready
+}
+
+func (h *Health) serveHealthz(w http.ResponseWriter, r *http.Request) {
+	report, _ := h.Report()
+	writeJSON(w, http.StatusOK, report)
+}
+
+func (h *Health) serveReadyz(w http.ResponseWriter, r *http.Request) {
+	report, ready := h.Report()
+	status := http.StatusOK
+	if !ready {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
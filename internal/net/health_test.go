@@ -0,0 +1,65 @@
+package netpkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"code_breaker/internal/game"
+)
+
+// getHealth fetches a health endpoint and decodes its report.
+func getHealth(t *testing.T, url string) (int, HealthReport) {
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var report HealthReport
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+	return resp.StatusCode, report
+}
+
+func TestHealth_ReportsCapacityListenersAndDraining(t *testing.T) {
+	lobby := newTestLobby(t, testConfig(2))
+	_, err := lobby.CreateRoom("solo", testConfig(1))
+	require.NoError(t, err)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() { _ = serve(listener, lobby) }()
+	health := newHealth(lobby)
+	health.addListener(listener)
+	httpServer := httptest.NewServer(newHTTPHandler(lobby, health, game.ServerConfig{}))
+	t.Cleanup(httpServer.Close)
+
+	dialPlayer(t, listener.Addr().String(), "alice", joinMain).expect(game.SESSION)
+
+	status, report := getHealth(t, httpServer.URL+readyzPath)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "ok", report.Status)
+	assert.Equal(t, []ListenerStatus{{Network: "tcp", Address: listener.Addr().String(), Up: true}}, report.Listeners)
+	assert.Equal(t, Capacity{Rooms: 2, WaitingRooms: 2, FreeSeats: 2, Players: 1}, report.Capacity)
+
+	health.listenerDown(listener.Addr(), errors.New("accept failed"))
+	status, report = getHealth(t, httpServer.URL+readyzPath)
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, "unavailable", report.Status)
+	assert.Equal(t, "accept failed", report.Listeners[0].Error)
+
+	lobby.Shutdown(context.Background(), false)
+	status, report = getHealth(t, httpServer.URL+readyzPath)
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, "draining", report.Status)
+	assert.True(t, report.Draining)
+	assert.Equal(t, Capacity{}, report.Capacity)
+
+	// the server is still alive while it drains
+	status, _ = getHealth(t, httpServer.URL+healthzPath)
+	assert.Equal(t, http.StatusOK, status)
+}
@@ -0,0 +1,83 @@
+package netpkg
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Addresses of listeners (LISTEN_ADDR) and of the server (SERVER_ADDR) take the forms
+//
+//	host:port, [ipv6]:port, :port   TCP
+//	tcp4://host:port                TCP over IPv4 only (tcp6:// for IPv6 only, tcp:// for both)
+//	unix:/path/to/socket            Unix domain socket
+const unixAddrPrefix = "unix:"
+
+// splitNetworkAddress returns the network and address to listen on or dial for addr.
+func splitNetworkAddress(addr string) (network, address string, err error) {
+	if path, ok := strings.CutPrefix(addr, unixAddrPrefix); ok {
+		path = strings.TrimPrefix(path, "//")
+		if path == "" {
+			return "", "", fmt.Errorf("%q: missing socket path", addr)
+		}
+		return "unix", path, nil
+	}
+
+	network, address = "tcp", addr
+	for _, n := range []string{"tcp", "tcp4", "tcp6"} {
+		if rest, ok := strings.CutPrefix(addr, n+"://"); ok {
+			network, address = n, rest
+			break
+		}
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return "", "", fmt.Errorf("%q: %w", addr, err)
+	}
+	return network, address, nil
+}
+
+// listen opens a listener for addr, serving TLS when tlsCfg is set.
+func listen(addr string, tlsCfg *tls.Config) (net.Listener, error) {
+	network, address, err := splitNetworkAddress(addr)
+	if err != nil {
+		return nil, err
+	}
+	if network == "unix" {
+		if err := removeStaleSocket(address); err != nil {
+			return nil, err
+		}
+	}
+
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+	if tlsCfg != nil {
+		listener = tls.NewListener(listener, tlsCfg)
+	}
+	return listener, nil
+}
+
+// removeStaleSocket removes the socket at path if it was left behind by a server that did not shut
+// down cleanly. A socket that still accepts connections belongs to a running server and is kept.
+func removeStaleSocket(path string) error {
+	if info, err := os.Stat(path); err != nil || info.Mode()&os.ModeSocket == 0 {
+		return nil
+	}
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	switch {
+	case err == nil:
+		_ = conn.Close()
+		return fmt.Errorf("listen unix %s: %w, another server is running", path, syscall.EADDRINUSE)
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return os.Remove(path)
+	case errors.Is(err, syscall.ENOENT):
+		return nil
+	}
+	return fmt.Errorf("listen unix %s: %w, cannot tell whether another server is running: %v", path, syscall.EADDRINUSE, err)
+}
@@ -0,0 +1,97 @@
+package netpkg
+
+import (
+	"net"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"code_breaker/internal/game"
+)
+
+func TestSplitNetworkAddress(t *testing.T) {
+	tests := []struct {
+		addr, network, address string
+	}{
+		{":8080", "tcp", ":8080"},
+		{"0.0.0.0:8080", "tcp", "0.0.0.0:8080"},
+		{"localhost:8080", "tcp", "localhost:8080"},
+		{"[::1]:8080", "tcp", "[::1]:8080"},
+		{"tcp4://127.0.0.1:8080", "tcp4", "127.0.0.1:8080"},
+		{"tcp6://[::]:8080", "tcp6", "[::]:8080"},
+		{"unix:/tmp/codebreaker.sock", "unix", "/tmp/codebreaker.sock"},
+		{"unix:///tmp/codebreaker.sock", "unix", "/tmp/codebreaker.sock"},
+		{"unix:codebreaker.sock", "unix", "codebreaker.sock"},
+	}
+	for _, tt := range tests {
+		network, address, err := splitNetworkAddress(tt.addr)
+		if assert.NoError(t, err, tt.addr) {
+			assert.Equal(t, tt.network, network, tt.addr)
+			assert.Equal(t, tt.address, address, tt.addr)
+		}
+	}
+
+	for _, addr := range []string{"", "8080", "::1:8080", "unix:", "tcp://localhost"} {
+		_, _, err := splitNetworkAddress(addr)
+		assert.Error(t, err, addr)
+	}
+}
+
+// serveListenAddr listens on addr and serves a test lobby, returning the address to dial.
+func serveListenAddr(t *testing.T, addr string) string {
+	listener, err := listen(addr, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() { _ = serve(listener, newTestLobby(t, testConfig(1))) }()
+	if listener.Addr().Network() == "unix" {
+		return unixAddrPrefix + listener.Addr().String()
+	}
+	return listener.Addr().String()
+}
+
+func TestListen_UnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "codebreaker.sock")
+	addr := serveListenAddr(t, unixAddrPrefix+path)
+	assert.NoError(t, dialTLS(t, ClientConfig{Address: addr}))
+
+	// a socket left behind is replaced
+	stale, err := net.Listen("unix", filepath.Join(t.TempDir(), "stale.sock"))
+	require.NoError(t, err)
+	stalePath := stale.Addr().String()
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, stale.Close())
+	addr = serveListenAddr(t, unixAddrPrefix+stalePath)
+	assert.NoError(t, dialTLS(t, ClientConfig{Address: addr}))
+
+	// the socket of a running server is not taken over
+	_, err = listen(unixAddrPrefix+path, nil)
+	assert.ErrorIs(t, err, syscall.EADDRINUSE)
+	assert.NoError(t, dialTLS(t, ClientConfig{Address: unixAddrPrefix + path}), "the first server still serves")
+}
+
+func TestListen_IPv6(t *testing.T) {
+	probe, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skip("IPv6 loopback unavailable:", err)
+	}
+	_ = probe.Close()
+
+	addr := serveListenAddr(t, "[::1]:0")
+	assert.NoError(t, dialTLS(t, ClientConfig{Address: addr}))
+}
+
+func TestListen_TLSOverUnixSocket(t *testing.T) {
+	caFile, certFile, keyFile := writeTestCertificates(t)
+	tlsCfg, err := serverTLSConfig(game.ServerConfig{TLSCertFile: certFile, TLSKeyFile: keyFile})
+	require.NoError(t, err)
+	addr := unixAddrPrefix + filepath.Join(t.TempDir(), "codebreaker.sock")
+	listener, err := listen(addr, tlsCfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() { _ = serve(listener, newTestLobby(t, testConfig(1))) }()
+
+	assert.NoError(t, dialTLS(t, ClientConfig{Address: addr, TLS: true, CAFile: caFile}))
+}
@@ -0,0 +1,669 @@
+package netpkg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"code_breaker/internal/game"
+)
+
+var roomNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,20}$`)
+
+var nicknamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{2,16}$`)
+
+// numberPattern matches what room commands such as /mute read as a seat number.
+var numberPattern = regexp.MustCompile(`^[0-9]+$`)
+
+// localePattern matches language tags such as en, en-US or pt_BR.
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,3}([-_][A-Za-z0-9]{2,8})?$`)
+
+// prefixes of server-assigned names, which players cannot pick
+const (
+	botNamePrefix   = "bot-"
+	guestNamePrefix = "guest-"
+)
+
+const lobbyHelp = "Commands: /hello [nickname] | /register <nickname> <password> | /login <nickname> <password> | /rooms | /join <room> | /watch <room> | /create [room] [settings] | /play [settings] | /cancel | /leaderboard [difficulty]\n" +
+	"Settings: players=N length=N difficulty=easy|medium|hard turn=SECONDS scoring=true|false games=N\n"
+
+// Lobby holds the rooms of a server and serves connections that have not joined one yet.
+type Lobby struct {
+	defaults   game.Config
+	matchmaker *Matchmaker
+	accounts   *AccountStore // nil when accounts are disabled
+	games      *GameStore
+	ratings    *Ratings
+	replayDir  string // records every game in this directory; empty disables replays
+	metrics    *Metrics
+
+	mu        sync.Mutex
+	rooms     map[string]*Room
+	order     []string
+	sessions  map[string]*Room       // session token -> room holding the seat
+	names     map[string]bool        // nicknames in use, lower case
+	conns     map[*clientConn]bool   // connections in the lobby
+	logins    map[string]*clientConn // the connection logged in to each account, lower case
+	nextID    int
+	nextGuest int
+	// shuttingDown refuses new connections and rooms
+	shuttingDown bool
+}
+
+// NewLobby creates an empty lobby; accounts may be nil to disable registration and login,
+// and games may be nil to keep the game records in memory only.
+func NewLobby(defaults game.Config, matchmaking game.MatchmakingConfig, accounts *AccountStore, games *GameStore) *Lobby {
+	if games == nil {
+		games = newMemoryGameStore()
+	}
+	l := &Lobby{
+		defaults: defaults,
+		accounts: accounts,
+		games:    games,
+		ratings:  rateGames(games.Records("")),
+		metrics:  newMetrics(),
+		rooms:    make(map[string]*Room),
+		sessions: make(map[string]*Room),
+		names:    make(map[string]bool),
+		conns:    make(map[*clientConn]bool),
+		logins:   make(map[string]*clientConn),
+	}
+	l.matchmaker = NewMatchmaker(l, matchmaking)
+	return l
+}
+
+// CreateRoom validates cfg and starts a room running it.
+// An empty name picks the next free "room-N".
+func (l *Lobby) CreateRoom(name string, cfg game.Config) (*Room, error) {
+	return l.createRoom(name, cfg, 0)
+}
+
+// createRoom is CreateRoom with some of the seats taken by bots.
+func (l *Lobby) createRoom(name string, cfg game.Config, bots int) (*Room, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.shuttingDown {
+		return nil, errShuttingDown
+	}
+	if name == "" {
+		for name == "" || l.rooms[name] != nil {
+			l.nextID++
+			name = fmt.Sprintf("room-%d", l.nextID)
+		}
+	}
+	if !roomNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("room name must be 1-20 letters, digits, '-' or '_'")
+	}
+	if l.rooms[name] != nil {
+		return nil, fmt.Errorf("room %s already exists", name)
+	}
+
+	room := newRoom(name, cfg, l)
+	room.bots = bots
+	l.rooms[name] = room
+	l.order = append(l.order, name)
+	go room.Run()
+	return room, nil
+}
+
+// Rooms lists all rooms in creation order.
+func (l *Lobby) Rooms() []game.RoomInfo {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	infos := make([]game.RoomInfo, 0, len(l.order))
+	for _, name := range l.order {
+		infos = append(infos, l.rooms[name].Info())
+	}
+	return infos
+}
+
+func (l *Lobby) room(name string) *Room {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rooms[name]
+}
+
+// CloseRoom ends a room, disconnecting everyone in it.
+func (l *Lobby) CloseRoom(name string) error {
+	room := l.room(name)
+	if room == nil {
+		return fmt.Errorf("room %s does not exist", name)
+	}
+	return room.do(func() error {
+		room.close(game.INFO, fmt.Sprintf("Room %s was closed by an administrator.\n", name))
+		return nil
+	})
+}
+
+// removeRoom forgets a closed room and its sessions.
+func (l *Lobby) removeRoom(room *Room) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rooms[room.name] == room {
+		delete(l.rooms, room.name)
+		for i, name := range l.order {
+			if name == room.name {
+				l.order = append(l.order[:i], l.order[i+1:]...)
+				break
+			}
+		}
+	}
+	for token, r := range l.sessions {
+		if r == room {
+			delete(l.sessions, token)
+		}
+	}
+}
+
+func (l *Lobby) registerSession(token string, room *Room) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sessions[token] = room
+}
+
+func (l *Lobby) removeSession(token string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.sessions, token)
+}
+
+func (l *Lobby) sessionRoom(token string) *Room {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.sessions[token]
+}
+
+// validateNickname checks the characters of a nickname and that the server did not reserve it.
+func validateNickname(name string) error {
+	if !nicknamePattern.MatchString(name) {
+		return fmt.Errorf("nickname must be 2-16 letters, digits, '-' or '_'")
+	}
+	if numberPattern.MatchString(name) {
+		return fmt.Errorf("nickname must not be a number, which commands read as a seat")
+	}
+	lower := strings.ToLower(name)
+	if strings.HasPrefix(lower, botNamePrefix) || strings.HasPrefix(lower, guestNamePrefix) {
+		return fmt.Errorf("nicknames starting with %q or %q are reserved", botNamePrefix, guestNamePrefix)
+	}
+	return nil
+}
+
+// claimName reserves a nickname for as long as its connection or seat exists.
+// Registered nicknames are only available through their account.
+func (l *Lobby) claimName(name string) error {
+	if err := validateNickname(name); err != nil {
+		return err
+	}
+	if l.accounts != nil && l.accounts.Exists(name) {
+		return fmt.Errorf("nickname %s belongs to an account, use %s", name, game.CmdLogin)
+	}
+	lower := strings.ToLower(name)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.names[lower] {
+		return fmt.Errorf("nickname %s is already taken", name)
+	}
+	l.names[lower] = true
+	return nil
+}
+
+// guestName reserves a name for a connection that skipped the handshake.
+func (l *Lobby) guestName() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for {
+		l.nextGuest++
+		name := fmt.Sprintf("%s%d", guestNamePrefix, l.nextGuest)
+		if !l.names[name] {
+			l.names[name] = true
+			return name
+		}
+	}
+}
+
+func (l *Lobby) releaseName(name string) {
+	if l.accounts != nil && l.accounts.Exists(name) {
+		// account names are never claimed
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.names, strings.ToLower(name))
+}
+
+// Serve reads lobby commands from conn until it is handed over to a room.
+func (l *Lobby) Serve(conn *clientConn) {
+	if !l.enter(conn) {
+		conn.send(game.SHUTDOWN, shutdownNotice)
+		_ = conn.Close()
+		return
+	}
+	defer l.leave(conn)
+
+	for {
+		line, err := conn.reader.ReadString('\n')
+		if err != nil {
+			l.drop(conn)
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case game.CmdHello:
+			if err := l.hello(conn, fields[1:]); err != nil {
+				conn.send(game.INFO, "Handshake failed: "+err.Error()+"\n")
+				continue
+			}
+			conn.send(game.INFO, fmt.Sprintf("Welcome %s!\n", conn.name))
+			l.sendRooms(conn)
+			continue
+
+		case game.CmdRegister, game.CmdLogin:
+			if err := l.authenticate(conn, fields[0], fields[1:]); err != nil {
+				conn.send(game.INFO, "Login failed: "+err.Error()+"\n")
+				continue
+			}
+			conn.send(game.INFO, fmt.Sprintf("Welcome %s! You are logged in.\n", conn.name))
+			for _, room := range l.seatRooms(conn.name) {
+				conn.send(game.INFO, fmt.Sprintf("You have a seat in room %s, %s %s to return to it.\n", room, game.CmdJoin, room))
+			}
+			l.sendRooms(conn)
+			continue
+
+		case game.CmdResume:
+			if len(fields) < 2 {
+				conn.send(game.INFO, "Usage: /resume <token> [locale=xx] [color=true|false]\n")
+				continue
+			}
+			if err := parsePreferences(conn, fields[2:]); err != nil {
+				conn.send(game.INFO, "Handshake failed: "+err.Error()+"\n")
+				continue
+			}
+			if room := l.sessionRoom(fields[1]); room != nil {
+				if conn.name != "" {
+					// the seat keeps the name it was taken with
+					l.releaseName(conn.name)
+				}
+				room.Join(conn, fields[1])
+				return
+			}
+			conn.send(game.INFO, "Session not found. Join or create a room to play.\n")
+			l.sendRooms(conn)
+			continue
+		}
+
+		if conn.name == "" {
+			conn.name = l.guestName()
+			conn.send(game.INFO, fmt.Sprintf("No nickname given, you are %s.\n", conn.name))
+		}
+
+		switch fields[0] {
+		case game.CmdRooms:
+			l.sendRooms(conn)
+
+		case game.CmdLeaderboard:
+			sendLeaderboard(l, conn.sendMessage, strings.Join(fields[1:], " "), l.defaults.Difficulty)
+
+		case game.CmdNotation:
+			conn.send(game.NOTATION, "Games are kept by their rooms, join or watch one first.\n")
+
+		case game.CmdJoin:
+			if len(fields) != 2 {
+				conn.send(game.INFO, "Usage: /join <room>\n")
+				continue
+			}
+			if room := l.room(fields[1]); room != nil {
+				room.Join(conn, "")
+				return
+			}
+			conn.send(game.INFO, fmt.Sprintf("Room %s does not exist.\n", fields[1]))
+
+		case game.CmdWatch:
+			if len(fields) != 2 {
+				conn.send(game.INFO, "Usage: /watch <room>\n")
+				continue
+			}
+			if room := l.room(fields[1]); room != nil {
+				room.Watch(conn)
+				return
+			}
+			conn.send(game.INFO, fmt.Sprintf("Room %s does not exist.\n", fields[1]))
+
+		case game.CmdCreate:
+			name, cfg, err := parseRoomOptions(l.defaults, fields[1:])
+			if err == nil {
+				var room *Room
+				if room, err = l.CreateRoom(name, cfg); err == nil {
+					room.Join(conn, "")
+					return
+				}
+			}
+			conn.send(game.INFO, "Cannot create room: "+err.Error()+"\n")
+
+		case game.CmdPlay:
+			name, cfg, err := parseRoomOptions(l.defaults, fields[1:])
+			if err == nil && name != "" {
+				err = fmt.Errorf("unexpected argument %q", name)
+			}
+			if err == nil {
+				err = cfg.Validate()
+			}
+			if err != nil {
+				conn.send(game.INFO, "Cannot join quick play: "+err.Error()+"\n")
+				continue
+			}
+			room, err := l.waitForMatch(conn, cfg, l.matchmaker.Enqueue(conn, cfg))
+			switch {
+			case err != nil:
+				// the client left while queued, or the server is shutting down
+				l.drop(conn)
+				return
+			case room != nil:
+				room.Join(conn, "")
+				return
+			}
+
+		default:
+			conn.send(game.INFO, "You are in the lobby. "+lobbyHelp)
+		}
+	}
+}
+
+// waitForMatch keeps reading conn while it waits in the quick-play queue for cfg, so the client
+// can leave the queue with CmdCancel and one that disconnects leaves it. It returns the room found,
+// nil after a cancel or if no room could be created, or the error that ended the connection.
+func (l *Lobby) waitForMatch(conn *clientConn, cfg game.Config, matched <-chan *Room) (*Room, error) {
+	found := make(chan *Room, 1)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case room := <-matched:
+			// wake the read below; the deadline is set first so it cannot hit the room's reads
+			_ = conn.SetReadDeadline(time.Now())
+			found <- room
+		case <-stop:
+		}
+	}()
+	matchedRoom := func(readAhead string) (*Room, error) {
+		room := <-found
+		_ = conn.SetReadDeadline(time.Time{})
+		conn.unread(readAhead)
+		if room == nil {
+			if l.Draining() {
+				return nil, errShuttingDown
+			}
+			conn.send(game.INFO, "Quick play failed, please try again.\n")
+		}
+		return room, nil
+	}
+
+	for {
+		line, err := conn.reader.ReadString('\n')
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return matchedRoom(line)
+		}
+		if err != nil {
+			if l.matchmaker.Dequeue(conn, cfg) {
+				return nil, err
+			}
+			// matched as it left: take the seat, which the room will see disconnect
+			return matchedRoom("")
+		}
+		if len(found) > 0 {
+			return matchedRoom(line)
+		}
+		if strings.TrimSpace(line) != game.CmdCancel {
+			conn.send(game.INFO, fmt.Sprintf("You are queued for quick play, %s to leave the queue.\n", game.CmdCancel))
+			continue
+		}
+		if !l.matchmaker.Dequeue(conn, cfg) {
+			return matchedRoom("")
+		}
+		conn.send(game.INFO, "You left the quick-play queue.\n")
+		l.sendRooms(conn)
+		return nil, nil
+	}
+}
+
+// drop ends a connection that left the lobby without joining a room.
+func (l *Lobby) drop(conn *clientConn) {
+	if conn.name != "" {
+		l.releaseName(conn.name)
+	}
+	l.logout(conn)
+	_ = conn.Close()
+}
+
+// Draining reports whether the server is shutting down.
+func (l *Lobby) Draining() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.shuttingDown
+}
+
+// enter registers a connection in the lobby; it reports false while the server shuts down.
+func (l *Lobby) enter(conn *clientConn) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.shuttingDown {
+		return false
+	}
+	l.conns[conn] = true
+	return true
+}
+
+func (l *Lobby) leave(conn *clientConn) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.conns, conn)
+}
+
+func (l *Lobby) sendRooms(conn *clientConn) {
+	rooms := l.Rooms()
+	var b strings.Builder
+	b.WriteString("====== LOBBY ======\n")
+	if len(rooms) == 0 {
+		b.WriteString("No rooms yet.\n")
+	}
+	for _, r := range rooms {
+		state := "waiting"
+		if r.Started {
+			state = "playing"
+		}
+		fmt.Fprintf(&b, "%s | players: %d/%d | spectators: %d | length: %d | difficulty: %s | turn: %ds | %s\n",
+			r.Name, r.Players, r.MaxPlayers, r.Spectators, r.CodeLength, r.Difficulty, r.TurnTimeSeconds, state)
+	}
+	b.WriteString(lobbyHelp)
+	conn.sendMessage(game.Message{Type: game.LOBBY, Text: b.String(), Rooms: rooms})
+}
+
+// hello handles the join handshake: "/hello [nickname] [preferences]".
+// Without a nickname the connection gets a guest name.
+func (l *Lobby) hello(conn *clientConn, args []string) error {
+	if len(args) == 0 || strings.Contains(args[0], "=") {
+		if conn.name == "" {
+			conn.name = l.guestName()
+		}
+		return parsePreferences(conn, args)
+	}
+
+	name := args[0]
+	if !strings.EqualFold(name, conn.name) {
+		if err := l.claimName(name); err != nil {
+			return err
+		}
+		if conn.name != "" {
+			l.releaseName(conn.name)
+		}
+		l.logout(conn)
+		conn.account = false
+	}
+	conn.name = name
+	return parsePreferences(conn, args[1:])
+}
+
+// authenticate handles "/register" and "/login": "<nickname> <password> [preferences]".
+func (l *Lobby) authenticate(conn *clientConn, cmd string, args []string) error {
+	if l.accounts == nil {
+		return errors.New("accounts are not enabled on this server")
+	}
+	if len(args) < 2 {
+		return fmt.Errorf("usage: %s <nickname> <password> [locale=xx] [color=true|false]", cmd)
+	}
+	name, password := args[0], args[1]
+
+	if cmd == game.CmdRegister {
+		if err := validateNickname(name); err != nil {
+			return err
+		}
+		l.mu.Lock()
+		taken := l.names[strings.ToLower(name)] && !strings.EqualFold(name, conn.name)
+		l.mu.Unlock()
+		if taken {
+			return fmt.Errorf("nickname %s is already taken", name)
+		}
+		if err := l.accounts.Register(name, password); err != nil {
+			return err
+		}
+	} else {
+		var err error
+		if name, err = l.accounts.Authenticate(name, password); err != nil {
+			return err
+		}
+	}
+
+	if conn.name != "" && !conn.account {
+		l.releaseName(conn.name)
+	}
+	l.logout(conn)
+	conn.name = name
+	conn.account = true
+	l.login(conn)
+	return parsePreferences(conn, args[2:])
+}
+
+// login makes conn the connection of its account. An account is used by one connection at
+// a time, so the one logged in before is told and closed; a seat it held waits for the new one.
+func (l *Lobby) login(conn *clientConn) {
+	l.mu.Lock()
+	old := l.logins[strings.ToLower(conn.name)]
+	l.logins[strings.ToLower(conn.name)] = conn
+	l.mu.Unlock()
+	if old != nil && old != conn {
+		old.send(game.INFO, fmt.Sprintf("You logged in to %s from another connection, this one is closed.\n", conn.name))
+		_ = old.Close()
+	}
+}
+
+// logout forgets conn as the connection of its account once it disconnects or changes its name.
+func (l *Lobby) logout(conn *clientConn) {
+	if !conn.account {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.logins[strings.ToLower(conn.name)] == conn {
+		delete(l.logins, strings.ToLower(conn.name))
+	}
+}
+
+// seatRooms lists the rooms in which name holds a seat.
+func (l *Lobby) seatRooms(name string) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var rooms []string
+	for _, roomName := range l.order {
+		if l.rooms[roomName].seat(name) != nil {
+			rooms = append(rooms, roomName)
+		}
+	}
+	return rooms
+}
+
+// parsePreferences reads the optional key=value preferences of a handshake.
+func parsePreferences(conn *clientConn, args []string) error {
+	for _, arg := range args {
+		key, val, _ := strings.Cut(arg, "=")
+		switch key {
+		case "locale":
+			if !localePattern.MatchString(val) {
+				return fmt.Errorf("invalid locale %q", val)
+			}
+			conn.locale = val
+		case "color":
+			color, err := strconv.ParseBool(val)
+			if err != nil {
+				return fmt.Errorf("color must be true or false")
+			}
+			conn.color = color
+		default:
+			return fmt.Errorf("unknown preference %q", key)
+		}
+	}
+	return nil
+}
+
+// parseRoomOptions reads "/create" arguments: an optional room name followed by key=value settings.
+func parseRoomOptions(defaults game.Config, args []string) (string, game.Config, error) {
+	cfg := defaults
+	name := ""
+	for _, arg := range args {
+		key, val, found := strings.Cut(arg, "=")
+		if !found {
+			if name != "" {
+				return "", cfg, fmt.Errorf("unexpected argument %q", arg)
+			}
+			name = arg
+			continue
+		}
+
+		if key == "difficulty" {
+			d, ok := game.ParseDifficulty(val)
+			if !ok {
+				return "", cfg, fmt.Errorf("unknown difficulty %q", val)
+			}
+			cfg.Difficulty = d
+			continue
+		}
+		if key == "scoring" {
+			b, err := strconv.ParseBool(val)
+			if err != nil {
+				return "", cfg, fmt.Errorf("scoring must be true or false")
+			}
+			cfg.Scoring = b
+			continue
+		}
+
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return "", cfg, fmt.Errorf("%s must be a number", key)
+		}
+		switch key {
+		case "players":
+			cfg.MaxPlayers = n
+		case "length":
+			cfg.CodeLength = n
+		case "turn":
+			cfg.TurnTimeSeconds = n
+		case "games":
+			cfg.MatchGames = n
+		default:
+			return "", cfg, fmt.Errorf("unknown setting %q", key)
+		}
+	}
+	return name, cfg, nil
+}
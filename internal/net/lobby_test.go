@@ -0,0 +1,234 @@
+package netpkg
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"code_breaker/internal/game"
+)
+
+var testDefaults = game.Config{
+	MaxPlayers:                  2,
+	CodeLength:                  4,
+	Difficulty:                  game.DifficultyMedium,
+	TurnTimeSeconds:             30,
+	SpectatorSecretDelaySeconds: -1,
+	ChatMaxLength:               20,
+}
+
+// testConfig returns testDefaults for the given number of players.
+func testConfig(players int) game.Config {
+	cfg := testDefaults
+	cfg.MaxPlayers = players
+	return cfg
+}
+
+func TestParseRoomOptions(t *testing.T) {
+	name, cfg, err := parseRoomOptions(testDefaults, []string{"fast", "players=3", "length=6", "difficulty=hard", "turn=10"})
+	require.NoError(t, err)
+	assert.Equal(t, "fast", name)
+	expected := testConfig(3)
+	expected.CodeLength = 6
+	expected.Difficulty = game.DifficultyHard
+	expected.TurnTimeSeconds = 10
+	assert.Equal(t, expected, cfg)
+
+	name, cfg, err = parseRoomOptions(testDefaults, nil)
+	require.NoError(t, err)
+	assert.Empty(t, name)
+	assert.Equal(t, testDefaults, cfg)
+
+	for _, args := range [][]string{
+		{"a", "b"},
+		{"players=two"},
+		{"difficulty=insane"},
+		{"colour=red"},
+	} {
+		_, _, err := parseRoomOptions(testDefaults, args)
+		assert.Error(t, err, "%v", args)
+	}
+}
+
+func TestLobby_CreateRoomValidates(t *testing.T) {
+	lobby := NewLobby(testDefaults, game.MatchmakingConfig{WaitSeconds: 1}, nil, nil)
+
+	_, err := lobby.CreateRoom("bad name!", testDefaults)
+	assert.Error(t, err)
+
+	hard2 := testDefaults
+	hard2.Difficulty = game.DifficultyHard
+	hard2.CodeLength = 2
+	_, err = lobby.CreateRoom("x", hard2)
+	assert.Error(t, err)
+
+	_, err = lobby.CreateRoom("x", testDefaults)
+	require.NoError(t, err)
+	_, err = lobby.CreateRoom("x", testDefaults)
+	assert.Error(t, err, "duplicate name")
+
+	auto, err := lobby.CreateRoom("", testDefaults)
+	require.NoError(t, err)
+	assert.Equal(t, "room-1", auto.Info().Name)
+	assert.Len(t, lobby.Rooms(), 2)
+}
+
+func TestLobby_RoomsRunConcurrentlyWithOwnSettings(t *testing.T) {
+	addr := startTestServer(t, testDefaults)
+
+	// a single-player room with 6 digit codes next to the 2 player default room
+	solo := dialPlayer(t, addr, "alice", game.CmdCreate+" solo players=1 length=6")
+	solo.expect(game.SESSION)
+	solo.expect(game.TURN)
+	solo.send("0000")
+	require.Contains(t, solo.expect(game.INFO).Text, "exactly 6 digits")
+	solo.send("000000")
+	require.Contains(t, solo.expect(game.RESULT).Text, "Number guessed: 0")
+
+	// the default room is still waiting for its players
+	other := dialTestClient(t, addr, game.CmdRooms)
+	rooms := other.expect(game.LOBBY).Rooms
+	require.Len(t, rooms, 2)
+	assert.Equal(t, DefaultRoom, rooms[0].Name)
+	assert.False(t, rooms[0].Started)
+	assert.Equal(t, "solo", rooms[1].Name)
+	assert.True(t, rooms[1].Started)
+	assert.Equal(t, 6, rooms[1].CodeLength)
+}
+
+func TestMatchmaking_GroupsPlayersByPreferences(t *testing.T) {
+	addr := startTestServer(t, testDefaults)
+
+	easy1 := dialPlayer(t, addr, "alice", game.CmdPlay+" difficulty=easy players=2")
+	require.Contains(t, easy1.expect(game.INFO).Text, "Queued for quick play (1/2")
+	hard := dialPlayer(t, addr, "bob", game.CmdPlay+" difficulty=hard players=2")
+	require.Contains(t, hard.expect(game.INFO).Text, "Queued for quick play (1/2")
+	easy2 := dialPlayer(t, addr, "carol", game.CmdPlay+" difficulty=easy players=2")
+
+	easy1.expect(game.SESSION)
+	easy2.expect(game.SESSION)
+	require.NotEqual(t, easy1.expectTurnOrWait(), easy2.expectTurnOrWait())
+
+	rooms := dialTestClient(t, addr, game.CmdRooms).expect(game.LOBBY).Rooms
+	require.Len(t, rooms, 2, "only the easy queue formed a room")
+	assert.Equal(t, game.DifficultyEasy, rooms[1].Difficulty)
+	assert.Equal(t, 2, rooms[1].Players)
+}
+
+func TestMatchmaking_FillsWithBotsAfterWait(t *testing.T) {
+	addr := startTestServer(t, testDefaults)
+
+	human := dialPlayer(t, addr, "alice", game.CmdPlay+" players=2")
+	human.expect(game.SESSION)
+	human.expect(game.INFO) // welcome
+	human.expect(game.INFO) // game starting
+	require.Equal(t, "bot-2 is a bot.\n", human.expect(game.INFO).Text)
+
+	// the bot takes its turn on its own
+	if human.expectTurnOrWait() {
+		human.send("0000")
+		human.expect(game.RESULT)
+	}
+	for {
+		result := human.expect(game.RESULT)
+		if strings.Contains(result.Text, "player: bot-2") {
+			break
+		}
+	}
+}
+
+func TestMatchmaking_CancelAndDisconnectLeaveTheQueue(t *testing.T) {
+	lobby := newTestLobby(t, testDefaults)
+	addr := serveTestLobby(t, lobby)
+
+	alice := dialPlayer(t, addr, "alice", game.CmdPlay+" players=2")
+	require.Contains(t, alice.expect(game.INFO).Text, "Queued for quick play (1/2")
+	alice.send("1234")
+	assert.Equal(t, "You are queued for quick play, /cancel to leave the queue.\n", alice.expect(game.INFO).Text)
+	alice.send(game.CmdCancel)
+	assert.Equal(t, "You left the quick-play queue.\n", alice.expect(game.INFO).Text)
+	alice.expect(game.LOBBY)
+
+	carol := dialPlayer(t, addr, "carol", game.CmdPlay+" players=2")
+	require.Contains(t, carol.expect(game.INFO).Text, "Queued for quick play (1/2", "alice left the queue")
+	_ = carol.conn.Close()
+	require.Eventually(t, func() bool {
+		lobby.matchmaker.mu.Lock()
+		defer lobby.matchmaker.mu.Unlock()
+		return len(lobby.matchmaker.queues) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	bob := dialPlayer(t, addr, "bob", game.CmdPlay+" players=2")
+	require.Contains(t, bob.expect(game.INFO).Text, "Queued for quick play (1/2", "carol left the queue")
+
+	// the lobby still serves alice after she left the queue
+	alice.send(game.CmdPlay + " players=2")
+	alice.expect(game.SESSION)
+	bob.expect(game.SESSION)
+}
+
+func TestMatchmaking_ClosesTheQueueWhenShuttingDown(t *testing.T) {
+	lobby := newTestLobby(t, testDefaults)
+	addr := serveTestLobby(t, lobby)
+
+	alice := dialPlayer(t, addr, "alice", game.CmdPlay+" players=2")
+	require.Contains(t, alice.expect(game.INFO).Text, "Queued for quick play (1/2")
+	lobby.mu.Lock()
+	lobby.shuttingDown = true
+	lobby.mu.Unlock()
+
+	// the wait runs out, and no room can be created any more
+	assert.Equal(t, shutdownNotice, alice.expect(game.SHUTDOWN).Text)
+	alice.expectClosed()
+}
+
+func TestLobby_HandshakeValidatesNicknames(t *testing.T) {
+	addr := startTestServer(t, testDefaults)
+
+	alice := dialPlayer(t, addr, "alice")
+
+	taken := dialTestClient(t, addr, game.CmdHello+" ALICE")
+	require.Contains(t, taken.expect(game.INFO).Text, "already taken")
+	taken.send(game.CmdHello + " no!")
+	require.Contains(t, taken.expect(game.INFO).Text, "letters, digits")
+	taken.send(game.CmdHello + " bot-7")
+	require.Contains(t, taken.expect(game.INFO).Text, "reserved")
+	taken.send(game.CmdHello + " bob color=maybe")
+	require.Contains(t, taken.expect(game.INFO).Text, "color must be")
+	taken.send(game.CmdHello + " 42")
+	require.Contains(t, taken.expect(game.INFO).Text, "must not be a number")
+	taken.send(game.CmdHello + " bob locale=english!")
+	require.Contains(t, taken.expect(game.INFO).Text, `invalid locale "english!"`)
+
+	// a name is free again once its connection leaves
+	_ = alice.conn.Close()
+	require.Eventually(t, func() bool {
+		c := dialTestClient(t, addr, game.CmdHello+" alice")
+		return c.expect(game.INFO).Text == "Welcome alice!\n"
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestParsePreferences(t *testing.T) {
+	conn := &clientConn{color: true}
+	require.NoError(t, parsePreferences(conn, []string{"locale=en-US", "color=false"}))
+	assert.Equal(t, "en-US", conn.locale)
+	assert.False(t, conn.color)
+
+	assert.EqualError(t, parsePreferences(conn, []string{"locale=x"}), `invalid locale "x"`)
+	assert.EqualError(t, parsePreferences(conn, []string{"theme=dark"}), `unknown preference "theme"`)
+	assert.Equal(t, "en-US", conn.locale)
+}
+
+func TestLobby_StripsColorsWhenUnsupported(t *testing.T) {
+	addr := startTestServer(t, testConfig(1))
+
+	plain := dialPlayer(t, addr, "alice color=false", game.CmdJoin+" "+DefaultRoom)
+	plain.expect(game.TURN)
+	plain.send("0000")
+	result := plain.expect(game.RESULT).Text
+	assert.Contains(t, result, "player: alice")
+	assert.NotContains(t, result, "\x1b[")
+}
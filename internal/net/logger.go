@@ -0,0 +1,216 @@
+package netpkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"code_breaker/internal/game"
+)
+
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l logLevel) String() string {
+	return levelNames[l]
+}
+
+func parseLogLevel(s string) (logLevel, error) {
+	for i, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return logLevel(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q, expected one of %s", s, strings.Join(levelNames, ", "))
+}
+
+// secret marks a logged value that gives a game away. It is redacted unless DEBUG_SECRETS is set.
+type secret int
+
+const redacted = "[redacted]"
+
+// Logger writes one line per event as logfmt or JSON: the time, level and message,
+// followed by the logger's context fields and the event's key-value pairs.
+type Logger struct {
+	core   *logCore
+	fields []interface{} // key-value pairs added to every line
+}
+
+// logCore is the output shared by a logger and the loggers derived from it.
+type logCore struct {
+	mu            sync.Mutex
+	out           io.Writer
+	level         logLevel
+	json          bool
+	revealSecrets bool
+}
+
+// serverLog is the logger of the server; StartServer configures it from the ServerConfig.
+var serverLog = &Logger{core: &logCore{out: os.Stderr, level: levelInfo}}
+
+// configureLogging sets the level, format and secret redaction of serverLog, which writes to out,
+// and routes the standard logger, used e.g. by net/http, through it as warnings.
+func configureLogging(out io.Writer, cfg game.ServerConfig) error {
+	level, err := parseLogLevel(cfg.LogLevel)
+	if err != nil {
+		return err
+	}
+	var asJSON bool
+	switch strings.ToLower(cfg.LogFormat) {
+	case "logfmt":
+	case "json":
+		asJSON = true
+	default:
+		return fmt.Errorf("unknown log format %q, expected logfmt or json", cfg.LogFormat)
+	}
+
+	c := serverLog.core
+	c.mu.Lock()
+	c.out, c.level, c.json, c.revealSecrets = out, level, asJSON, cfg.DebugSecrets
+	c.mu.Unlock()
+
+	log.SetFlags(0)
+	log.SetOutput(stdLogWriter{serverLog})
+	return nil
+}
+
+// With returns a logger that adds the given key-value pairs to every line.
+func (l *Logger) With(kv ...interface{}) *Logger {
+	fields := make([]interface{}, 0, len(l.fields)+len(kv))
+	return &Logger{core: l.core, fields: append(append(fields, l.fields...), kv...)}
+}
+
+func (l *Logger) Debug(msg string, kv ...interface{}) { l.log(levelDebug, msg, kv) }
+func (l *Logger) Info(msg string, kv ...interface{})  { l.log(levelInfo, msg, kv) }
+func (l *Logger) Warn(msg string, kv ...interface{})  { l.log(levelWarn, msg, kv) }
+func (l *Logger) Error(msg string, kv ...interface{}) { l.log(levelError, msg, kv) }
+
+// Fatal logs an error and exits.
+func (l *Logger) Fatal(msg string, kv ...interface{}) {
+	l.log(levelError, msg, kv)
+	os.Exit(1)
+}
+
+func (l *Logger) log(level logLevel, msg string, kv []interface{}) {
+	c := l.core
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if level < c.level {
+		return
+	}
+
+	pairs := append([]interface{}{"time", time.Now().UTC().Format(time.RFC3339Nano), "level", level.String(), "msg", msg}, l.fields...)
+	pairs = append(pairs, kv...)
+	if len(pairs)%2 != 0 {
+		pairs = append(pairs, "")
+	}
+
+	var b bytes.Buffer
+	if c.json {
+		b.WriteByte('{')
+	}
+	for i := 0; i < len(pairs); i += 2 {
+		key := fmt.Sprint(pairs[i])
+		value := c.resolve(pairs[i+1])
+		switch {
+		case c.json:
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeJSONValue(&b, key)
+			b.WriteByte(':')
+			writeJSONValue(&b, value)
+		default:
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteString(key)
+			b.WriteByte('=')
+			b.WriteString(logfmtValue(value))
+		}
+	}
+	if c.json {
+		b.WriteByte('}')
+	}
+	b.WriteByte('\n')
+	_, _ = c.out.Write(b.Bytes())
+}
+
+// resolve turns a logged value into what is written: secrets are redacted
+// and errors and other Stringers are written as text.
+func (c *logCore) resolve(v interface{}) interface{} {
+	switch v := v.(type) {
+	case secret:
+		if !c.revealSecrets {
+			return redacted
+		}
+		return int(v)
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	}
+	return v
+}
+
+func writeJSONValue(b *bytes.Buffer, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(v))
+	}
+	b.Write(data)
+}
+
+// logfmtValue formats a value for logfmt, quoting strings that need it.
+// Maps, slices and structs are written as JSON, types based on string as the plain string.
+func logfmtValue(v interface{}) string {
+	var s string
+	switch v := v.(type) {
+	case string:
+		s = v
+	case int, int64, uint64, float64, bool:
+		return fmt.Sprint(v)
+	default:
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.String {
+			s = rv.String()
+			break
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			s = fmt.Sprint(v)
+		} else {
+			s = string(data)
+		}
+	}
+	if s == "" || strings.IndexFunc(s, func(r rune) bool { return r <= ' ' || r == '=' || r == '"' || !unicode.IsPrint(r) }) >= 0 {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// stdLogWriter logs what is written to the standard logger as warnings.
+type stdLogWriter struct {
+	log *Logger
+}
+
+func (w stdLogWriter) Write(p []byte) (int, error) {
+	w.log.Warn(strings.TrimSpace(string(p)))
+	return len(p), nil
+}
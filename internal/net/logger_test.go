@@ -0,0 +1,97 @@
+package netpkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"code_breaker/internal/game"
+)
+
+// captureLogs sends the server log to a buffer until the test ends.
+func captureLogs(t *testing.T, cfg game.ServerConfig) *syncBuffer {
+	var b syncBuffer
+	require.NoError(t, configureLogging(&b, cfg))
+	t.Cleanup(func() {
+		require.NoError(t, configureLogging(os.Stderr, game.ServerConfig{LogLevel: "info", LogFormat: "logfmt"}))
+	})
+	return &b
+}
+
+// syncBuffer is a buffer that the room goroutines may write to while the test reads it.
+type syncBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.String()
+}
+
+func TestLogger_Logfmt(t *testing.T) {
+	logs := captureLogs(t, game.ServerConfig{LogLevel: "info", LogFormat: "logfmt"})
+	l := serverLog.With("room", "main", "game", 2)
+	l.Debug("hidden")
+	l.Info("player connected", "player", 1, "name", "alice", "err", errors.New("read: connection reset"), "wins", map[string]int{"alice": 1}, "difficulty", game.DifficultyHard)
+
+	line := logs.String()
+	assert.NotContains(t, line, "hidden")
+	assert.Regexp(t, `^time=\S+ level=info msg="player connected" room=main game=2 player=1 name=alice `+
+		`err="read: connection reset" wins="{\\"alice\\":1}" difficulty=hard\n$`, line)
+}
+
+func TestLogger_JSON(t *testing.T) {
+	logs := captureLogs(t, game.ServerConfig{LogLevel: "debug", LogFormat: "json"})
+	serverLog.With("room", "main").Debug("new secret", "secret", secret(1234))
+	log.Printf("http: TLS handshake error")
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	require.Len(t, lines, 2)
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, "debug", entry["level"])
+	assert.Equal(t, "new secret", entry["msg"])
+	assert.Equal(t, "main", entry["room"])
+	assert.Equal(t, redacted, entry["secret"])
+
+	// the standard logger goes through the server log
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &entry))
+	assert.Equal(t, "warn", entry["level"])
+	assert.Equal(t, "http: TLS handshake error", entry["msg"])
+}
+
+func TestLogger_RevealsSecretsOnlyWhenAsked(t *testing.T) {
+	logs := captureLogs(t, game.ServerConfig{LogLevel: "debug", LogFormat: "logfmt", DebugSecrets: true})
+	serverLog.Debug("new secret", "secret", secret(1234))
+	assert.Contains(t, logs.String(), " secret=1234\n")
+}
+
+func TestRoom_RedactsTheSecretInLogs(t *testing.T) {
+	logs := captureLogs(t, game.ServerConfig{LogLevel: "debug", LogFormat: "logfmt"})
+	addr := startTestServer(t, testConfig(1))
+	dialPlayer(t, addr, "alice", joinMain).expect(game.TURN)
+
+	assert.Regexp(t, `level=debug msg="new secret" room=main game=1 secret=\[redacted\]`, logs.String())
+	assert.Regexp(t, `level=info msg="player connected" room=main game=0 player=1 name=alice`, logs.String())
+}
+
+func TestConfigureLogging_RejectsUnknownSettings(t *testing.T) {
+	assert.Error(t, configureLogging(os.Stderr, game.ServerConfig{LogLevel: "verbose", LogFormat: "logfmt"}))
+	assert.Error(t, configureLogging(os.Stderr, game.ServerConfig{LogLevel: "info", LogFormat: "xml"}))
+}
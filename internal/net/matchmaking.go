@@ -0,0 +1,134 @@
+package netpkg
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"code_breaker/internal/game"
+)
+
+// queueEntry is a connection waiting in the quick-play queue.
+type queueEntry struct {
+	conn    *clientConn
+	matched chan *Room
+}
+
+// Matchmaker groups quick-play connections by the settings they asked for.
+// A queue starts a room as soon as it has enough players, or when its wait
+// time runs out with whoever is present, optionally filling the rest with bots.
+type Matchmaker struct {
+	lobby *Lobby
+	cfg   game.MatchmakingConfig
+
+	mu     sync.Mutex
+	queues map[game.Config][]*queueEntry
+	timers map[game.Config]*time.Timer
+}
+
+func NewMatchmaker(lobby *Lobby, cfg game.MatchmakingConfig) *Matchmaker {
+	return &Matchmaker{
+		lobby:  lobby,
+		cfg:    cfg,
+		queues: make(map[game.Config][]*queueEntry),
+		timers: make(map[game.Config]*time.Timer),
+	}
+}
+
+// Enqueue adds conn to the queue for cfg and returns the channel its room will be sent on:
+// nil if the room could not be created, in which case conn is closed if the server is shutting down.
+func (m *Matchmaker) Enqueue(conn *clientConn, cfg game.Config) <-chan *Room {
+	entry := &queueEntry{conn: conn, matched: make(chan *Room, 1)}
+
+	m.mu.Lock()
+	m.queues[cfg] = append(m.queues[cfg], entry)
+	waiting := len(m.queues[cfg])
+	var full []*queueEntry
+	if waiting >= cfg.MaxPlayers {
+		full = m.takeLocked(cfg)
+	} else if m.timers[cfg] == nil {
+		var timer *time.Timer
+		timer = time.AfterFunc(time.Duration(m.cfg.WaitSeconds)*time.Second, func() {
+			m.mu.Lock()
+			if m.timers[cfg] != timer {
+				// the queue filled up before the wait ran out
+				m.mu.Unlock()
+				return
+			}
+			entries := m.takeLocked(cfg)
+			m.mu.Unlock()
+			m.start(cfg, entries, m.cfg.FillWithBots)
+		})
+		m.timers[cfg] = timer
+	}
+	m.mu.Unlock()
+
+	if full != nil {
+		m.start(cfg, full, false)
+	} else {
+		conn.send(game.INFO, fmt.Sprintf(
+			"Queued for quick play (%d/%d players, %s). The game starts when the queue is full or in %ds.\n",
+			waiting, cfg.MaxPlayers, cfg.Difficulty, m.cfg.WaitSeconds))
+	}
+	return entry.matched
+}
+
+// Dequeue removes conn from the queue for cfg. It reports false if conn is no longer queued,
+// because a room was already found for it.
+func (m *Matchmaker) Dequeue(conn *clientConn, cfg game.Config) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entries := m.queues[cfg]
+	for i, e := range entries {
+		if e.conn != conn {
+			continue
+		}
+		m.queues[cfg] = append(entries[:i:i], entries[i+1:]...)
+		if len(m.queues[cfg]) == 0 {
+			m.takeLocked(cfg)
+		}
+		return true
+	}
+	return false
+}
+
+// takeLocked empties the queue for cfg; m.mu must be held.
+func (m *Matchmaker) takeLocked(cfg game.Config) []*queueEntry {
+	entries := m.queues[cfg]
+	delete(m.queues, cfg)
+	if timer := m.timers[cfg]; timer != nil {
+		timer.Stop()
+		delete(m.timers, cfg)
+	}
+	return entries
+}
+
+// start opens a room for the given entries.
+func (m *Matchmaker) start(cfg game.Config, entries []*queueEntry, withBots bool) {
+	if len(entries) == 0 {
+		return
+	}
+
+	bots := 0
+	if withBots {
+		bots = cfg.MaxPlayers - len(entries)
+	}
+	roomCfg := cfg
+	roomCfg.MaxPlayers = len(entries) + bots
+
+	room, err := m.lobby.createRoom("", roomCfg, bots)
+	switch {
+	case errors.Is(err, errShuttingDown):
+		for _, e := range entries {
+			e.conn.send(game.SHUTDOWN, shutdownNotice)
+			_ = e.conn.Close()
+		}
+	case err != nil:
+		// cfg was validated when queued, so this is not expected
+		serverLog.Error("error creating quick-play room", "err", err)
+	}
+	for _, e := range entries {
+		e.matched <- room
+	}
+}
@@ -0,0 +1,252 @@
+package netpkg
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"code_breaker/internal/game"
+)
+
+// metricsPath serves the metrics in the Prometheus text format.
+const metricsPath = "/metrics"
+
+// Metrics counts what happens in the lobby and its rooms.
+// Game metrics are labelled with the difficulty and code length of the game.
+type Metrics struct {
+	connections   *counter
+	gamesStarted  *counter
+	gamesWon      *counter
+	guesses       *counter
+	timeouts      *counter
+	recoveries    *counter
+	invalidInputs *counter
+	turnDuration  *histogram
+	guessesToWin  *histogram
+}
+
+var gameLabels = []string{"difficulty", "code_length"}
+
+func newMetrics() *Metrics {
+	return &Metrics{
+		connections:   newCounter("codebreaker_connections_total", "Client connections accepted.", "transport"),
+		gamesStarted:  newCounter("codebreaker_games_started_total", "Games started.", gameLabels...),
+		gamesWon:      newCounter("codebreaker_games_won_total", "Games won.", gameLabels...),
+		guesses:       newCounter("codebreaker_guesses_total", "Valid guesses by players and bots.", gameLabels...),
+		timeouts:      newCounter("codebreaker_turn_timeouts_total", "Turns that ran out of time.", gameLabels...),
+		recoveries:    newCounter("codebreaker_recoveries_total", "Times all players timed out in a row.", gameLabels...),
+		invalidInputs: newCounter("codebreaker_invalid_inputs_total", "Guesses rejected as invalid.", gameLabels...),
+		turnDuration: newHistogram("codebreaker_turn_duration_seconds", "Time players took for their turn, until they guessed or ran out of time.",
+			[]float64{1, 2, 5, 10, 15, 20, 30, 45, 60, 120}, gameLabels...),
+		guessesToWin: newHistogram("codebreaker_guesses_to_win", "Guesses made in a game until it was won.",
+			[]float64{1, 2, 3, 4, 5, 6, 8, 10, 12, 15, 20, 30}, gameLabels...),
+	}
+}
+
+// gameLabelValues are the values of gameLabels for cfg.
+func gameLabelValues(cfg game.Config) []string {
+	return []string{string(cfg.Difficulty), strconv.Itoa(cfg.CodeLength)}
+}
+
+// metricsHandler serves the lobby's metrics along with the current number of rooms, players and connections.
+func metricsHandler(lobby *Lobby) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		lobby.metrics.write(w)
+
+		rooms := newGauge("codebreaker_rooms", "Open rooms.", "state")
+		players := newGauge("codebreaker_players", "Seats taken in the open rooms, including bots.")
+		spectators := newGauge("codebreaker_spectators", "Spectators in the open rooms.")
+		rooms.set(0, "waiting")
+		rooms.set(0, "playing")
+		for _, info := range lobby.Rooms() {
+			state := "waiting"
+			if info.Started {
+				state = "playing"
+			}
+			rooms.add(1, state)
+			players.add(float64(info.Players))
+			spectators.add(float64(info.Spectators))
+		}
+		lobbyConns := newGauge("codebreaker_lobby_connections", "Connections in the lobby, not in a room.")
+		lobby.mu.Lock()
+		lobbyConns.set(float64(len(lobby.conns)))
+		lobby.mu.Unlock()
+		for _, g := range []*counter{rooms, players, spectators, lobbyConns} {
+			g.write(w)
+		}
+	})
+}
+
+func (m *Metrics) write(w io.Writer) {
+	for _, c := range []*counter{m.connections, m.gamesStarted, m.gamesWon, m.guesses, m.timeouts, m.recoveries, m.invalidInputs} {
+		c.write(w)
+	}
+	m.turnDuration.write(w)
+	m.guessesToWin.write(w)
+}
+
+// counter is a metric with one value per combination of label values.
+// Gauges share its implementation.
+type counter struct {
+	name, help, kind string
+	labels           []string
+
+	mu     sync.Mutex
+	values map[string]float64 // by labelKey
+}
+
+func newCounter(name, help string, labels ...string) *counter {
+	return &counter{name: name, help: help, kind: "counter", labels: labels, values: make(map[string]float64)}
+}
+
+func newGauge(name, help string, labels ...string) *counter {
+	g := newCounter(name, help, labels...)
+	g.kind = "gauge"
+	return g
+}
+
+func (c *counter) inc(labelValues ...string) {
+	c.add(1, labelValues...)
+}
+
+func (c *counter) add(v float64, labelValues ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[labelKey(labelValues)] += v
+}
+
+func (c *counter) set(v float64, labelValues ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[labelKey(labelValues)] = v
+}
+
+func (c *counter) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	writeHeader(w, c.name, c.help, c.kind)
+	if len(c.labels) == 0 && len(c.values) == 0 {
+		fmt.Fprintf(w, "%s 0\n", c.name)
+	}
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labels, key), formatValue(c.values[key]))
+	}
+}
+
+// histogram counts observations in cumulative buckets, per combination of label values.
+type histogram struct {
+	name, help string
+	labels     []string
+	buckets    []float64 // upper bounds, ascending
+
+	mu     sync.Mutex
+	series map[string]*histogramSeries // by labelKey
+}
+
+type histogramSeries struct {
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+func newHistogram(name, help string, buckets []float64, labels ...string) *histogram {
+	return &histogram{name: name, help: help, labels: labels, buckets: buckets, series: make(map[string]*histogramSeries)}
+}
+
+func (h *histogram) observe(v float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := labelKey(labelValues)
+	s := h.series[key]
+	if s == nil {
+		s = &histogramSeries{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += v
+}
+
+// observeDuration records d in seconds.
+func (h *histogram) observeDuration(d time.Duration, labelValues ...string) {
+	h.observe(d.Seconds(), labelValues...)
+}
+
+func (h *histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	writeHeader(w, h.name, h.help, "histogram")
+	keys := make([]string, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	bucketLabels := append(append([]string{}, h.labels...), "le")
+	for _, key := range keys {
+		s := h.series[key]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(bucketLabels, key+labelSep+formatValue(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(bucketLabels, key+labelSep+"+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, key), formatValue(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, key), s.count)
+	}
+}
+
+// labelSep joins label values into map keys; it cannot occur in them.
+const labelSep = "\x00"
+
+func labelKey(values []string) string {
+	return strings.Join(values, labelSep)
+}
+
+// formatLabels renders the label values joined in key as {name="value",...}.
+func formatLabels(names []string, key string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	values := strings.Split(key, labelSep)
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"", name, labelValueEscaper.Replace(value))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func writeHeader(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys(values map[string]float64) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
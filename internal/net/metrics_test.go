@@ -0,0 +1,84 @@
+package netpkg
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"code_breaker/internal/game"
+)
+
+func TestHistogram_WritesCumulativeBuckets(t *testing.T) {
+	h := newHistogram("test_seconds", "Test.", []float64{1, 5}, "kind")
+	h.observe(0.5, "a")
+	h.observe(1, "a")
+	h.observe(3, "a")
+	h.observe(10, "a")
+
+	var b bytes.Buffer
+	h.write(&b)
+	assert.Equal(t, `# HELP test_seconds Test.
+# TYPE test_seconds histogram
+test_seconds_bucket{kind="a",le="1"} 2
+test_seconds_bucket{kind="a",le="5"} 3
+test_seconds_bucket{kind="a",le="+Inf"} 4
+test_seconds_sum{kind="a"} 14.5
+test_seconds_count{kind="a"} 4
+`, b.String())
+}
+
+func TestCounter_EscapesLabelValues(t *testing.T) {
+	c := newCounter("test_total", "Test.", "name")
+	c.inc(`a"b\c`)
+	c.add(2, "plain")
+
+	var b bytes.Buffer
+	c.write(&b)
+	assert.Contains(t, b.String(), `test_total{name="a\"b\\c"} 1`+"\n")
+	assert.Contains(t, b.String(), `test_total{name="plain"} 2`+"\n")
+}
+
+func TestMetrics_CountGamesAndConnections(t *testing.T) {
+	lobby := newTestLobby(t, testConfig(1))
+	addr := serveTestLobby(t, lobby)
+	httpServer := httptest.NewServer(newTestHTTPHandler(lobby, game.ServerConfig{}))
+	t.Cleanup(httpServer.Close)
+
+	player := dialPlayer(t, addr, "alice", joinMain)
+	player.expect(game.TURN)
+	player.send("12")
+	require.Contains(t, player.expect(game.INFO).Text, "Invalid input")
+	player.expect(game.TURN)
+
+	guessSecret(t, lobby.room(DefaultRoom), player)
+	player.expect(game.WIN)
+
+	resp, err := http.Get(httpServer.URL + metricsPath)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/plain")
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	labels := `difficulty="medium",code_length="4"`
+	for _, line := range []string{
+		`codebreaker_connections_total{transport="tcp"} 1`,
+		`codebreaker_games_started_total{` + labels + `} 1`,
+		`codebreaker_games_won_total{` + labels + `} 1`,
+		`codebreaker_guesses_total{` + labels + `} 1`,
+		`codebreaker_invalid_inputs_total{` + labels + `} 1`,
+		`codebreaker_turn_duration_seconds_count{` + labels + `} 2`,
+		`codebreaker_guesses_to_win_bucket{` + labels + `,le="1"} 1`,
+		`codebreaker_rooms{state="playing"} 1`,
+		`codebreaker_rooms{state="waiting"} 0`,
+		`codebreaker_players 1`,
+		`codebreaker_lobby_connections 0`,
+	} {
+		assert.Contains(t, string(body), line+"\n")
+	}
+}
@@ -0,0 +1,116 @@
+package netpkg
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"code_breaker/internal/game"
+)
+
+// recordNotation writes a recorded game in notation.
+func recordNotation(rec GameRecord) game.GameNotation {
+	secret := rec.Secret
+	n := game.GameNotation{
+		Room:       rec.Room,
+		Game:       rec.Game,
+		Date:       rec.Started,
+		CodeLength: rec.Config.CodeLength,
+		Difficulty: rec.Config.Difficulty,
+		Result:     rec.Winner,
+		Secret:     &secret,
+		Salt:       rec.Salt,
+		Guesses:    append([]game.GuessRecord{}, rec.Guesses...),
+	}
+	if rec.Salt != "" {
+		n.Commitment = game.CommitSecret(rec.Secret, rec.Config.CodeLength, rec.Salt)
+	}
+	if n.Result == "" {
+		n.Result = game.ResultAbandoned
+	}
+	for _, p := range rec.Players {
+		n.Players = append(n.Players, game.NotationPlayer{Name: p.Name, Bot: p.Bot})
+	}
+	return n
+}
+
+// currentNotation writes the game in progress in notation, with its secret hidden.
+func (r *Room) currentNotation() game.GameNotation {
+	n := game.GameNotation{
+		Room:       r.name,
+		Game:       r.gameNumber,
+		Date:       r.gameStarted.UTC(),
+		CodeLength: r.cfg.CodeLength,
+		Difficulty: r.cfg.Difficulty,
+		Result:     game.ResultOngoing,
+		Commitment: r.commitment(),
+		Guesses:    append([]game.GuessRecord{}, r.history...),
+	}
+	for _, p := range r.gamePlayers {
+		n.Players = append(n.Players, game.NotationPlayer{Name: p.Name, Bot: p.Bot})
+	}
+	return n
+}
+
+// sendNotation sends p the game in progress in notation, or with arg "last", or between games,
+// the last game the room finished.
+func (r *Room) sendNotation(p *Player, arg string) {
+	if arg != "" && arg != "last" {
+		p.send(game.NOTATION, "Usage: "+game.CmdNotation+" [last]\n")
+		return
+	}
+	if arg == "" && r.started && !r.gameOver {
+		sendNotation(p.sendMessage, r.currentNotation())
+		return
+	}
+	records := r.lobby.games.Records(r.name)
+	if len(records) == 0 {
+		p.send(game.NOTATION, "No game has finished in this room yet.\n")
+		return
+	}
+	sendNotation(p.sendMessage, recordNotation(records[len(records)-1]))
+}
+
+// sendNotation sends n as the reply to CmdNotation.
+func sendNotation(send func(game.Message), n game.GameNotation) {
+	text := n.Format()
+	send(game.Message{Type: game.NOTATION, Text: text, Notation: text})
+}
+
+// RunValidate implements the validate command: it checks that every game of a notation file
+// is consistent, and writes what is wrong with those that are not.
+func RunValidate(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: validate <file>")
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	games, err := game.ParseNotation(f)
+	_ = f.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %w", flags.Arg(0), err)
+	}
+	invalid := 0
+	for _, g := range games {
+		if err := g.Validate(); err != nil {
+			invalid++
+			fmt.Fprintf(stdout, "Game %d of room %s is inconsistent:\n%v\n", g.Game, g.Room, err)
+			continue
+		}
+		fmt.Fprintf(stdout, "Game %d of room %s is consistent.\n", g.Game, g.Room)
+	}
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d games are inconsistent", invalid, len(games))
+	}
+	return nil
+}
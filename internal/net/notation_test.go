@@ -0,0 +1,131 @@
+package netpkg
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"code_breaker/internal/game"
+)
+
+func TestRoom_SendsGamesInNotation(t *testing.T) {
+	lobby := newTestLobby(t, testConfig(1))
+	room := lobby.room(DefaultRoom)
+	addr := serveTestLobby(t, lobby)
+
+	// every reply to the command is a NOTATION message, so the client knows its /save was answered
+	alice := dialPlayer(t, addr, "alice")
+	alice.send(game.CmdNotation)
+	assert.Empty(t, alice.expect(game.NOTATION).Notation)
+	alice.send(joinMain)
+	alice.expect(game.TURN)
+	alice.send(game.CmdNotation + " last")
+	reply := alice.expect(game.NOTATION)
+	assert.Equal(t, "No game has finished in this room yet.\n", reply.Text)
+	assert.Empty(t, reply.Notation)
+	alice.send(game.CmdNotation + " first")
+	assert.Empty(t, alice.expect(game.NOTATION).Notation)
+
+	alice.send("0000")
+	alice.expect(game.TURN)
+	alice.send(game.CmdNotation)
+	games, err := game.ParseNotation(strings.NewReader(alice.expect(game.NOTATION).Notation))
+	require.NoError(t, err)
+	require.Len(t, games, 1)
+	current := games[0]
+	assert.Equal(t, game.ResultOngoing, current.Result)
+	assert.Nil(t, current.Secret)
+	assert.Equal(t, []game.NotationPlayer{{Name: "alice"}}, current.Players)
+	require.Len(t, current.Guesses, 1)
+	assert.Equal(t, 0, current.Guesses[0].Guess)
+	assert.NoError(t, current.Validate())
+
+	guessSecret(t, room, alice)
+	alice.expect(game.WIN)
+	alice.send(game.CmdNotation + " last")
+	games, err = game.ParseNotation(strings.NewReader(alice.expect(game.NOTATION).Text))
+	require.NoError(t, err)
+	finished := games[0]
+	assert.Equal(t, "alice", finished.Result)
+	require.NotNil(t, finished.Secret)
+	require.Len(t, finished.Guesses, 2)
+	assert.Equal(t, *finished.Secret, finished.Guesses[1].Guess)
+	assert.NoError(t, finished.Validate())
+}
+
+func TestWriteExport_Notation(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, writeExport(&out, exportTestRecords(), exportGames, exportNotation))
+	games, err := game.ParseNotation(&out)
+	require.NoError(t, err)
+	require.Len(t, games, 2)
+	assert.Equal(t, "alice", games[0].Result)
+	assert.Equal(t, []game.NotationPlayer{{Name: "alice"}, {Name: "bot-1", Bot: true}}, games[0].Players)
+	assert.Len(t, games[0].Guesses, 3)
+	assert.Equal(t, game.ResultAbandoned, games[1].Result)
+	assert.Equal(t, 4321, *games[1].Secret)
+
+	assert.EqualError(t, writeExport(&out, nil, exportGuesses, exportNotation), "format notation only exports games")
+}
+
+func TestRunValidate(t *testing.T) {
+	dir := t.TempDir()
+	secret := 4213
+	g := game.GameNotation{Room: "main", Game: 1, CodeLength: 4, Difficulty: game.DifficultyEasy,
+		Players: []game.NotationPlayer{{Name: "alice"}}, Result: "alice", Secret: &secret,
+		Guesses: []game.GuessRecord{{PlayerName: "alice", Guess: 4213, CorrectPlace: 4}}}
+	valid := filepath.Join(dir, "valid"+game.NotationExtension)
+	require.NoError(t, os.WriteFile(valid, []byte(g.Format()), 0o600))
+
+	var stdout bytes.Buffer
+	require.NoError(t, RunValidate([]string{valid}, &stdout))
+	assert.Equal(t, "Game 1 of room main is consistent.\n", stdout.String())
+
+	g.Guesses[0].Guess = 4231
+	invalid := filepath.Join(dir, "invalid"+game.NotationExtension)
+	require.NoError(t, os.WriteFile(invalid, []byte(g.Format()), 0o600))
+	stdout.Reset()
+	assert.EqualError(t, RunValidate([]string{invalid}, &stdout), "1 of 1 games are inconsistent")
+	assert.Contains(t, stdout.String(), "guess 1: 4231 scores 2/2 against the secret, not 4/0")
+
+	assert.Error(t, RunValidate(nil, &stdout))
+}
+
+func TestRoom_KeepsKickedPlayersInTheNotation(t *testing.T) {
+	lobby := newTestLobby(t, testConfig(2))
+	room := lobby.room(DefaultRoom)
+	addr := serveTestLobby(t, lobby)
+
+	alice := dialPlayer(t, addr, "alice", joinMain)
+	alice.expect(game.SESSION)
+	bob := dialPlayer(t, addr, "bob", joinMain)
+	if !alice.expectTurnOrWait() {
+		bob.expect(game.TURN)
+		bob.send("0000")
+		alice.expect(game.TURN)
+	}
+	alice.send("0000")
+	bob.expect(game.TURN)
+
+	// alice leaves seat 1 after guessing, and bob moves up to it
+	require.NoError(t, room.Kick("alice"))
+	guessSecret(t, room, bob)
+	bob.expect(game.WIN)
+
+	records := lobby.games.Records(DefaultRoom)
+	require.Len(t, records, 1)
+	assert.Equal(t, []RecordedPlayer{{Name: "alice"}, {Name: "bob"}}, records[0].Players)
+	for _, guess := range records[0].Guesses {
+		assert.Equal(t, guess.PlayerName, records[0].Players[guess.Player-1].Name)
+	}
+
+	bob.send(game.CmdNotation + " last")
+	games, err := game.ParseNotation(strings.NewReader(bob.expect(game.NOTATION).Notation))
+	require.NoError(t, err)
+	assert.NoError(t, games[0].Validate())
+}
@@ -0,0 +1,232 @@
+package netpkg
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"code_breaker/internal/game"
+)
+
+var ansiColorPattern = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// clientConn is an accepted connection together with its buffered reader,
+// so bytes read in the lobby are not lost, and what the client told about itself
+// in the handshake. Messages to the client are queued and written by a goroutine of
+// its own, so sending never waits for the client.
+type clientConn struct {
+	net.Conn
+	reader *bufio.Reader
+
+	name    string
+	account bool   // name is a registered account the client logged in to
+	locale  string // the client's preferred locale, e.g. en or en-US
+	color   bool   // the client renders ANSI colors
+
+	writeTimeout time.Duration
+	queue        chan []byte
+	closing      chan struct{} // closed by Close: write what is queued, then close
+	done         chan struct{} // closed once the writer is gone
+	closeOnce    sync.Once
+}
+
+// newClientConn wraps conn and starts its writer; a write that takes longer than writeTimeout
+// drops the client.
+func newClientConn(conn net.Conn, writeTimeout time.Duration) *clientConn {
+	c := &clientConn{
+		Conn:         conn,
+		reader:       bufio.NewReader(conn),
+		color:        true,
+		writeTimeout: writeTimeout,
+		queue:        make(chan []byte, sendQueueSize),
+		closing:      make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	go c.writeQueued()
+	return c
+}
+
+// unread puts back input read ahead, so the next reader of the connection sees it first.
+func (c *clientConn) unread(s string) {
+	if s != "" {
+		c.reader = bufio.NewReader(io.MultiReader(strings.NewReader(s), c.reader))
+	}
+}
+
+func (c *clientConn) send(msgType game.MessageType, text string) {
+	c.sendMessage(game.Message{Type: msgType, Text: text})
+}
+
+// sendMessage queues msg for the client. A client whose queue is full has stopped reading
+// and is dropped; the reader of the connection then sees it leave, and a seat can be resumed.
+func (c *clientConn) sendMessage(msg game.Message) {
+	if !c.color {
+		msg.Text = ansiColorPattern.ReplaceAllString(msg.Text, "")
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		serverLog.Error("error marshaling message", "err", err)
+		return
+	}
+	select {
+	case <-c.closing:
+		return
+	case <-c.done:
+		return
+	default:
+	}
+	select {
+	case c.queue <- append(data, '\n'):
+	default:
+		serverLog.Warn("client is not reading, dropping the connection", "remote_addr", c.RemoteAddr())
+		c.drop()
+	}
+}
+
+// Close closes the connection once the messages already queued are written.
+func (c *clientConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closing) })
+	return nil
+}
+
+// drop closes the connection at once, discarding what is queued.
+func (c *clientConn) drop() {
+	c.closeOnce.Do(func() { close(c.closing) })
+	_ = c.Conn.Close()
+}
+
+// writeQueued writes the queued messages until the connection is closed or a write fails.
+// Closing allows one more writeTimeout for what is still queued.
+func (c *clientConn) writeQueued() {
+	defer close(c.done)
+	defer c.Conn.Close()
+	for {
+		select {
+		case data := <-c.queue:
+			_ = c.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+			if !c.write(data) {
+				return
+			}
+		case <-c.closing:
+			_ = c.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+			for {
+				select {
+				case data := <-c.queue:
+					if !c.write(data) {
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (c *clientConn) write(data []byte) bool {
+	if _, err := c.Conn.Write(data); err != nil {
+		serverLog.Warn("error writing to client, dropping the connection", "remote_addr", c.RemoteAddr(), "err", err)
+		return false
+	}
+	return true
+}
+
+// Player is a seat at the table. The seat outlives its connection:
+// a client that drops can reclaim it by presenting the session token.
+type Player struct {
+	id    int
+	name  string
+	token string
+	bot   bool // seated by matchmaking, plays on its own and never connects
+	// account seats can also be reclaimed by logging in to the account
+	account bool
+	// spectator connections watch a room without a seat; they have id 0
+	spectator bool
+	// muted holds the connections whose chat this one does not want to see
+	muted map[*Player]bool
+
+	mu   sync.Mutex
+	conn *clientConn // nil while disconnected
+}
+
+// attach binds conn to the seat, closing any connection it replaces.
+func (p *Player) attach(conn *clientConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn != nil {
+		_ = p.conn.Close()
+	}
+	p.conn = conn
+}
+
+// detach releases the seat's connection if it is still conn.
+func (p *Player) detach(conn *clientConn) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn != conn {
+		return false
+	}
+	_ = p.conn.Close()
+	p.conn = nil
+	return true
+}
+
+// disconnect closes the seat's connection, if any.
+func (p *Player) disconnect() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn != nil {
+		_ = p.conn.Close()
+		p.conn = nil
+	}
+}
+
+func (p *Player) isConn(conn *clientConn) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.conn == conn
+}
+
+func (p *Player) connected() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.conn != nil
+}
+
+// send writes to the player; messages for a disconnected seat are dropped.
+func (p *Player) send(msgType game.MessageType, text string) {
+	p.sendMessage(game.Message{Type: msgType, Text: text})
+}
+
+func (p *Player) sendMessage(msg game.Message) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn == nil {
+		return
+	}
+	p.conn.sendMessage(msg)
+}
+
+// newCommitmentSalt returns the random salt of the commitment to a game's secret.
+func newCommitmentSalt() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("crypto/rand unavailable: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
+
+func newSessionToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("crypto/rand unavailable: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,192 @@
+package netpkg
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"code_breaker/internal/game"
+)
+
+// apiLeaderboardPath serves the leaderboards:
+//
+//	GET /api/leaderboard?difficulty=&limit=
+//
+// difficulty defaults to the server's and limit to leaderboardSize.
+const apiLeaderboardPath = "/api/leaderboard"
+
+const (
+	initialRating   = 1500.0
+	ratingK         = 32.0 // the most a rating moves in a two-player game
+	leaderboardSize = 10
+)
+
+// Ratings keeps an Elo rating per nickname, on a separate ladder for each difficulty.
+// A game won with N players counts as the winner beating each of the others, and the others
+// drawing among themselves, each pairing weighted 1/(N-1). Bots and guests are opponents
+// with the initial rating but are not rated themselves, and games without a winner or
+// without an opponent are not rated.
+type Ratings struct {
+	mu      sync.Mutex
+	ladders map[game.Difficulty]map[string]*rating
+}
+
+type rating struct {
+	value float64
+	games int
+	wins  int
+}
+
+func newRatings() *Ratings {
+	return &Ratings{ladders: make(map[game.Difficulty]map[string]*rating)}
+}
+
+// rateGames computes the ratings from the records, oldest first.
+func rateGames(records []GameRecord) *Ratings {
+	r := newRatings()
+	for _, rec := range records {
+		r.add(rec)
+	}
+	return r
+}
+
+// rated reports whether a player of a recorded game has a rating.
+func rated(p RecordedPlayer) bool {
+	return !p.Bot && !strings.HasPrefix(strings.ToLower(p.Name), guestNamePrefix)
+}
+
+// add updates the ratings with the outcome of a game.
+func (r *Ratings) add(rec GameRecord) {
+	n := len(rec.Players)
+	if rec.Winner == "" || n < 2 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ladder := r.ladders[rec.Config.Difficulty]
+	if ladder == nil {
+		ladder = make(map[string]*rating)
+		r.ladders[rec.Config.Difficulty] = ladder
+	}
+
+	before := make([]float64, n)
+	for i, p := range rec.Players {
+		before[i] = initialRating
+		if rt := ladder[p.Name]; rt != nil && rated(p) {
+			before[i] = rt.value
+		}
+	}
+	for i, p := range rec.Players {
+		if !rated(p) {
+			continue
+		}
+		var delta float64
+		for j, opponent := range rec.Players {
+			if i == j {
+				continue
+			}
+			score := 0.5
+			if p.Name == rec.Winner {
+				score = 1
+			} else if opponent.Name == rec.Winner {
+				score = 0
+			}
+			expected := 1 / (1 + math.Pow(10, (before[j]-before[i])/400))
+			delta += score - expected
+		}
+
+		rt := ladder[p.Name]
+		if rt == nil {
+			rt = &rating{value: initialRating}
+			ladder[p.Name] = rt
+		}
+		rt.value += ratingK / float64(n-1) * delta
+		rt.games++
+		if p.Name == rec.Winner {
+			rt.wins++
+		}
+	}
+}
+
+// Leaderboard returns the best rated players of a difficulty, at most limit of them.
+func (r *Ratings) Leaderboard(difficulty game.Difficulty, limit int) game.Leaderboard {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	board := game.Leaderboard{Difficulty: difficulty, Players: make([]game.RatedPlayer, 0)}
+	for name, rt := range r.ladders[difficulty] {
+		board.Players = append(board.Players, game.RatedPlayer{Name: name, Rating: int(math.Round(rt.value)), Games: rt.games, Wins: rt.wins})
+	}
+	sort.Slice(board.Players, func(i, j int) bool {
+		a, b := board.Players[i], board.Players[j]
+		if a.Rating != b.Rating {
+			return a.Rating > b.Rating
+		}
+		return a.Name < b.Name
+	})
+	if len(board.Players) > limit {
+		board.Players = board.Players[:limit]
+	}
+	for i := range board.Players {
+		board.Players[i].Rank = i + 1
+	}
+	return board
+}
+
+// sendLeaderboard answers the leaderboard command; arg names the difficulty, defaulting to difficulty.
+func sendLeaderboard(l *Lobby, send func(game.Message), arg string, difficulty game.Difficulty) {
+	if arg != "" {
+		d, ok := game.ParseDifficulty(arg)
+		if !ok {
+			send(game.Message{Type: game.INFO, Text: "Usage: /leaderboard [easy|medium|hard]\n"})
+			return
+		}
+		difficulty = d
+	}
+	board := l.ratings.Leaderboard(difficulty, leaderboardSize)
+	send(game.Message{Type: game.LEADERBOARD, Text: formatLeaderboard(board), Leaderboard: &board})
+}
+
+func formatLeaderboard(board game.Leaderboard) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "====== LEADERBOARD (%s) ======\n", board.Difficulty)
+	if len(board.Players) == 0 {
+		b.WriteString("No rated games yet.\n")
+	}
+	for _, p := range board.Players {
+		fmt.Fprintf(&b, "%d. %s | rating: %d | games: %d | wins: %d\n", p.Rank, p.Name, p.Rating, p.Games, p.Wins)
+	}
+	return b.String()
+}
+
+func leaderboardHandler(lobby *Lobby) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		query := r.URL.Query()
+		difficulty := lobby.defaults.Difficulty
+		if val := query.Get("difficulty"); val != "" {
+			d, ok := game.ParseDifficulty(val)
+			if !ok {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("invalid difficulty %q", val))
+				return
+			}
+			difficulty = d
+		}
+		limit := leaderboardSize
+		if val := query.Get("limit"); val != "" {
+			n, err := strconv.Atoi(val)
+			if err != nil || n <= 0 {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("invalid limit %q", val))
+				return
+			}
+			limit = n
+		}
+		writeJSON(w, http.StatusOK, lobby.ratings.Leaderboard(difficulty, limit))
+	})
+}
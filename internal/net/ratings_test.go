@@ -0,0 +1,135 @@
+package netpkg
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"code_breaker/internal/game"
+)
+
+func ratedGame(difficulty game.Difficulty, winner string, players ...RecordedPlayer) GameRecord {
+	return GameRecord{Config: RecordedConfig{Difficulty: difficulty}, Winner: winner, Players: players}
+}
+
+func TestRatings_TwoPlayerGame(t *testing.T) {
+	r := rateGames([]GameRecord{
+		ratedGame(game.DifficultyMedium, "alice", RecordedPlayer{Name: "alice"}, RecordedPlayer{Name: "bob"}),
+	})
+	assert.Equal(t, []game.RatedPlayer{
+		{Rank: 1, Name: "alice", Rating: 1516, Games: 1, Wins: 1},
+		{Rank: 2, Name: "bob", Rating: 1484, Games: 1},
+	}, r.Leaderboard(game.DifficultyMedium, 10).Players)
+	assert.Empty(t, r.Leaderboard(game.DifficultyHard, 10).Players)
+}
+
+func TestRatings_AccountForOpponentsAndGameSize(t *testing.T) {
+	alice, bob, carol := RecordedPlayer{Name: "alice"}, RecordedPlayer{Name: "bob"}, RecordedPlayer{Name: "carol"}
+	r := rateGames([]GameRecord{
+		ratedGame(game.DifficultyEasy, "alice", alice, bob),
+		ratedGame(game.DifficultyEasy, "alice", alice, bob),
+		// beating a stronger player gains more than the stronger player gained beating bob
+		ratedGame(game.DifficultyEasy, "carol", carol, alice),
+		// the losers of a three-player game draw among themselves
+		ratedGame(game.DifficultyHard, "alice", alice, bob, carol),
+	})
+	board := r.Leaderboard(game.DifficultyEasy, 10)
+	ratings := make(map[string]int)
+	for _, p := range board.Players {
+		ratings[p.Name] = p.Rating
+	}
+	assert.Greater(t, ratings["carol"]-1500, 16)
+	assert.Less(t, ratings["bob"], 1500)
+
+	hard := r.Leaderboard(game.DifficultyHard, 10).Players
+	require.Len(t, hard, 3)
+	assert.Equal(t, game.RatedPlayer{Rank: 1, Name: "alice", Rating: 1516, Games: 1, Wins: 1}, hard[0])
+	assert.Equal(t, 1492, hard[1].Rating)
+	assert.Equal(t, 1492, hard[2].Rating)
+
+	assert.Len(t, r.Leaderboard(game.DifficultyEasy, 2).Players, 2)
+}
+
+func TestRatings_SkipBotsGuestsAndUnratedGames(t *testing.T) {
+	r := rateGames([]GameRecord{
+		ratedGame(game.DifficultyMedium, "alice", RecordedPlayer{Name: "alice"}, RecordedPlayer{Name: "bot-1", Bot: true}),
+		ratedGame(game.DifficultyMedium, "guest-1", RecordedPlayer{Name: "guest-1"}, RecordedPlayer{Name: "bob"}),
+		ratedGame(game.DifficultyMedium, "carol", RecordedPlayer{Name: "carol"}),                         // no opponent
+		ratedGame(game.DifficultyMedium, "", RecordedPlayer{Name: "dave"}, RecordedPlayer{Name: "erin"}), // abandoned
+	})
+	assert.Equal(t, []game.RatedPlayer{
+		{Rank: 1, Name: "alice", Rating: 1516, Games: 1, Wins: 1},
+		{Rank: 2, Name: "bob", Rating: 1484, Games: 1},
+	}, r.Leaderboard(game.DifficultyMedium, 10).Players)
+}
+
+func TestLobby_LeaderboardCommandAndAPI(t *testing.T) {
+	store := newMemoryGameStore()
+	for _, rec := range []GameRecord{
+		ratedGame(game.DifficultyHard, "alice", RecordedPlayer{Name: "alice"}, RecordedPlayer{Name: "bob"}),
+		ratedGame(game.DifficultyMedium, "bob", RecordedPlayer{Name: "alice"}, RecordedPlayer{Name: "bob"}),
+	} {
+		rec := rec
+		require.NoError(t, store.Append(&rec))
+	}
+	lobby := NewLobby(testDefaults, game.MatchmakingConfig{WaitSeconds: 1}, nil, store)
+	addr := serveTestLobby(t, lobby)
+
+	client := dialPlayer(t, addr, "carol", game.CmdLeaderboard)
+	msg := client.expect(game.LEADERBOARD)
+	require.NotNil(t, msg.Leaderboard)
+	assert.Equal(t, game.DifficultyMedium, msg.Leaderboard.Difficulty)
+	assert.Equal(t, "bob", msg.Leaderboard.Players[0].Name)
+	assert.Contains(t, msg.Text, "1. bob | rating: 1516 | games: 1 | wins: 1\n")
+
+	client.send(game.CmdLeaderboard + " hard")
+	msg = client.expect(game.LEADERBOARD)
+	assert.Equal(t, "alice", msg.Leaderboard.Players[0].Name)
+
+	handler := newTestHTTPHandler(lobby, game.ServerConfig{})
+	get := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, apiLeaderboardPath+query, nil))
+		return rec
+	}
+	resp := get("?difficulty=hard&limit=1")
+	require.Equal(t, http.StatusOK, resp.Code)
+	var board game.Leaderboard
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &board))
+	assert.Equal(t, game.Leaderboard{Difficulty: game.DifficultyHard, Players: []game.RatedPlayer{
+		{Rank: 1, Name: "alice", Rating: 1516, Games: 1, Wins: 1},
+	}}, board)
+	assert.Equal(t, http.StatusBadRequest, get("?difficulty=insane").Code)
+	assert.Equal(t, http.StatusBadRequest, get("?limit=-1").Code)
+}
+
+func TestRoom_RatesTheGamesItRecords(t *testing.T) {
+	lobby := newTestLobby(t, testConfig(2))
+	room := lobby.room(DefaultRoom)
+	addr := serveTestLobby(t, lobby)
+
+	alice := dialPlayer(t, addr, "alice", joinMain)
+	bob := dialPlayer(t, addr, "bob", joinMain)
+	// the first turn goes to a random player, who wins
+	winner, winnerName, loser, loserName := alice, "alice", bob, "bob"
+	if !alice.expectTurnOrWait() {
+		winner, winnerName, loser, loserName = bob, "bob", alice, "alice"
+	}
+	guessSecret(t, room, winner)
+	winner.expect(game.WIN)
+	loser.expect(game.WIN)
+
+	records := lobby.games.Records(DefaultRoom)
+	require.Len(t, records, 1)
+	assert.Equal(t, winnerName, records[0].Winner)
+
+	loser.send(game.CmdLeaderboard)
+	players := loser.expect(game.LEADERBOARD).Leaderboard.Players
+	require.Len(t, players, 2)
+	assert.Equal(t, game.RatedPlayer{Rank: 1, Name: winnerName, Rating: 1516, Games: 1, Wins: 1}, players[0])
+	assert.Equal(t, game.RatedPlayer{Rank: 2, Name: loserName, Rating: 1484, Games: 1}, players[1])
+}
@@ -0,0 +1,291 @@
+package netpkg
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"code_breaker/internal/game"
+)
+
+// replayVersion is the version of the replay file format, written in the game event.
+const replayVersion = 1
+
+// The events of a replay file.
+const (
+	replayGame     = "game"     // first line: version, room, game, started, config, seed, commitment
+	replayJoin     = "join"     // a seat at the start of the game: player, seat, bot
+	replayLeave    = "leave"    // a player disconnected or, with reason "kicked", lost the seat
+	replayRejoin   = "rejoin"   // a player reconnected
+	replayTurn     = "turn"     // the turn passed to player; timeLimitSeconds is 0 without a limit
+	replayGuess    = "guess"    // guess: the evaluated guess; points in scoring mode
+	replayInvalid  = "invalid"  // player sent input that is not a valid guess
+	replayTimeout  = "timeout"  // player ran out of time
+	replayRecovery = "recovery" // every player timed out, anyone may guess
+	replayWin      = "win"      // last line of a won game: player, secret, salt
+	replayEnd      = "end"      // last line of a game nobody won: reason, secret, salt
+)
+
+// ReplayEvent is a line of a replay file. Every event has a type and its time in milliseconds since
+// the game started; the other fields are set as listed with the event types.
+type ReplayEvent struct {
+	Type             string            `json:"type"`
+	At               int64             `json:"at"`
+	Version          int               `json:"version,omitempty"`
+	Room             string            `json:"room,omitempty"`
+	Game             int               `json:"game,omitempty"`
+	Started          *time.Time        `json:"started,omitempty"`
+	Config           *RecordedConfig   `json:"config,omitempty"`
+	Seed             int64             `json:"seed,omitempty"` // seeds the hints and the bots of the game
+	Player           string            `json:"player,omitempty"`
+	Seat             int               `json:"seat,omitempty"`
+	Bot              bool              `json:"bot,omitempty"`
+	TimeLimitSeconds int               `json:"timeLimitSeconds,omitempty"`
+	Guess            *game.GuessRecord `json:"guess,omitempty"`
+	Points           int               `json:"points,omitempty"`
+	Input            string            `json:"input,omitempty"`
+	Secret           *int              `json:"secret,omitempty"`
+	Reason           string            `json:"reason,omitempty"`
+	Commitment       string            `json:"commitment,omitempty"` // the commitment to the secret, see game.CommitSecret
+	Salt             string            `json:"salt,omitempty"`
+}
+
+// replayWriter appends the events of a game to its replay file as they happen.
+type replayWriter struct {
+	file    *os.File
+	started time.Time
+}
+
+// EnableReplays records every game from now on in a replay file in dir, which is created if missing.
+func (l *Lobby) EnableReplays(dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.replayDir = dir
+	return nil
+}
+
+func (l *Lobby) replayDirectory() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.replayDir
+}
+
+// startReplay opens the replay file of the game that just started, if replays are enabled.
+func (r *Room) startReplay() {
+	dir := r.lobby.replayDirectory()
+	if dir == "" {
+		return
+	}
+	name := fmt.Sprintf("%s-%s-%d.jsonl", r.name, r.gameStarted.UTC().Format("20060102-150405"), r.gameNumber)
+	f, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL|os.O_APPEND, 0o600)
+	if err != nil {
+		r.logger().Error("error creating replay file", "err", err)
+		return
+	}
+	r.replay = &replayWriter{file: f, started: r.gameStarted}
+
+	started := r.gameStarted.UTC()
+	r.recordEvent(ReplayEvent{Type: replayGame, Version: replayVersion, Room: r.name, Game: r.gameNumber,
+		Started: &started, Config: recordedConfig(r.cfg), Seed: r.seed, Commitment: r.commitment()})
+	for _, p := range r.players {
+		r.recordEvent(ReplayEvent{Type: replayJoin, Player: p.name, Seat: p.id, Bot: p.bot})
+	}
+}
+
+// recordEvent appends ev to the replay of the game in progress, if there is one.
+func (r *Room) recordEvent(ev ReplayEvent) {
+	if r.replay == nil {
+		return
+	}
+	ev.At = time.Since(r.replay.started).Milliseconds()
+	data, err := json.Marshal(ev)
+	if err == nil {
+		_, err = r.replay.file.Write(append(data, '\n'))
+	}
+	if err != nil {
+		r.logger().Error("error writing replay, the rest of the game is not recorded", "err", err)
+		r.closeReplay()
+	}
+}
+
+// finishReplay records the end of the game and closes its replay.
+func (r *Room) finishReplay(winner *Player) {
+	if r.replay == nil {
+		return
+	}
+	secret := r.secret
+	if winner != nil {
+		r.recordEvent(ReplayEvent{Type: replayWin, Player: winner.name, Secret: &secret, Salt: r.salt})
+	} else {
+		r.recordEvent(ReplayEvent{Type: replayEnd, Reason: "abandoned", Secret: &secret, Salt: r.salt})
+	}
+	r.closeReplay()
+}
+
+func (r *Room) closeReplay() {
+	if r.replay == nil {
+		return
+	}
+	if err := r.replay.file.Close(); err != nil {
+		r.logger().Error("error closing replay file", "err", err)
+	}
+	r.replay = nil
+}
+
+// ReadReplay parses a replay file, which must start with a game event.
+func ReadReplay(in io.Reader) ([]ReplayEvent, error) {
+	var events []ReplayEvent
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var ev ReplayEvent
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if len(events) == 0 && (ev.Type != replayGame || ev.Version != replayVersion) {
+			return nil, fmt.Errorf("line %d: not a version %d replay", line, replayVersion)
+		}
+		events = append(events, ev)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, errors.New("empty replay")
+	}
+	return events, nil
+}
+
+// formatReplayEvent describes an event for the terminal.
+func formatReplayEvent(ev ReplayEvent, codeLength int) string {
+	switch ev.Type {
+	case replayGame:
+		cfg := RecordedConfig{}
+		if ev.Config != nil {
+			cfg = *ev.Config
+		}
+		started := ""
+		if ev.Started != nil {
+			started = ", started " + ev.Started.Format(time.RFC3339)
+		}
+		return fmt.Sprintf("Replay of game %d in room %s%s\nPlayers: %d | length: %d | difficulty: %s | turn: %ds\n",
+			ev.Game, ev.Room, started, cfg.MaxPlayers, cfg.CodeLength, cfg.Difficulty, cfg.TurnTimeSeconds)
+	case replayJoin:
+		kind := ""
+		if ev.Bot {
+			kind = ", bot"
+		}
+		return fmt.Sprintf("%s joined (seat %d%s)\n", ev.Player, ev.Seat, kind)
+	case replayLeave:
+		if ev.Reason != "" {
+			return fmt.Sprintf("%s left (%s)\n", ev.Player, ev.Reason)
+		}
+		return fmt.Sprintf("%s disconnected\n", ev.Player)
+	case replayRejoin:
+		return fmt.Sprintf("%s reconnected\n", ev.Player)
+	case replayTurn:
+		if ev.TimeLimitSeconds > 0 {
+			return fmt.Sprintf("%s's turn (%ds)\n", ev.Player, ev.TimeLimitSeconds)
+		}
+		return fmt.Sprintf("%s's turn\n", ev.Player)
+	case replayGuess:
+		if ev.Guess == nil {
+			return "guess missing\n"
+		}
+		g := ev.Guess
+		text := fmt.Sprintf("%s guessed %s: correctly placed %d, wrongly placed %d, hint: %s",
+			g.PlayerName, formatCode(g.Guess, codeLength), g.CorrectPlace, g.WrongPlace, g.Hint)
+		if ev.Points != 0 {
+			text += fmt.Sprintf(" (%+d points)", ev.Points)
+		}
+		return text + "\n"
+	case replayInvalid:
+		return fmt.Sprintf("%s sent an invalid guess: %q\n", ev.Player, ev.Input)
+	case replayTimeout:
+		return fmt.Sprintf("%s ran out of time\n", ev.Player)
+	case replayRecovery:
+		return "All players timed out, anyone may guess\n"
+	case replayWin:
+		return fmt.Sprintf("%s won! The secret was %s\n", ev.Player, formatReplaySecret(ev.Secret, codeLength))
+	case replayEnd:
+		return fmt.Sprintf("Game over (%s). The secret was %s\n", ev.Reason, formatReplaySecret(ev.Secret, codeLength))
+	}
+	return fmt.Sprintf("unknown event %q\n", ev.Type)
+}
+
+func formatReplaySecret(secret *int, codeLength int) string {
+	if secret == nil {
+		return "not recorded"
+	}
+	return formatCode(*secret, codeLength)
+}
+
+// RunReplay implements the replay command: it prints a replay file with the timing of the game,
+// sped up by -speed, or all at once with -speed 0.
+func RunReplay(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	flags.SetOutput(stdout)
+	speed := flags.Float64("speed", 1, "playback speed, e.g. 2 for twice as fast; 0 prints the whole game at once")
+	maxWait := flags.Duration("max-wait", 5*time.Second, "longest pause between two events, 0 for no limit")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: replay [-speed N] [-max-wait D] <file>")
+	}
+	if *speed < 0 {
+		return errors.New("speed must not be negative")
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	events, err := ReadReplay(f)
+	_ = f.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %w", flags.Arg(0), err)
+	}
+	playReplay(stdout, events, *speed, *maxWait, time.Sleep)
+	return nil
+}
+
+// playReplay writes the events, waiting between them with sleep as the game did, divided by speed.
+func playReplay(out io.Writer, events []ReplayEvent, speed float64, maxWait time.Duration, sleep func(time.Duration)) {
+	codeLength := 0
+	if cfg := events[0].Config; cfg != nil {
+		codeLength = cfg.CodeLength
+	}
+	var last int64
+	for _, ev := range events {
+		if speed > 0 && ev.At > last {
+			wait := time.Duration(float64(time.Duration(ev.At-last)*time.Millisecond) / speed)
+			if maxWait > 0 && wait > maxWait {
+				wait = maxWait
+			}
+			sleep(wait)
+		}
+		if ev.At > last {
+			last = ev.At
+		}
+		fmt.Fprintf(out, "[%s] %s", formatReplayTime(ev.At), formatReplayEvent(ev, codeLength))
+	}
+}
+
+// formatReplayTime writes milliseconds as minutes, seconds and tenths.
+func formatReplayTime(ms int64) string {
+	return fmt.Sprintf("%02d:%02d.%d", ms/60000, ms/1000%60, ms/100%10)
+}
@@ -0,0 +1,103 @@
+package netpkg
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"code_breaker/internal/game"
+)
+
+func TestRoom_RecordsReplay(t *testing.T) {
+	dir := t.TempDir()
+	lobby := newTestLobby(t, testConfig(1))
+	require.NoError(t, lobby.EnableReplays(dir))
+	room := lobby.room(DefaultRoom)
+	addr := serveTestLobby(t, lobby)
+
+	alice := dialPlayer(t, addr, "alice", joinMain)
+	alice.expect(game.TURN)
+	alice.send("12")
+	alice.expect(game.TURN)
+	alice.send("0000")
+	alice.expect(game.TURN)
+	guessSecret(t, room, alice)
+	alice.expect(game.WIN)
+
+	files, err := filepath.Glob(filepath.Join(dir, "main-*-1.jsonl"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	f, err := os.Open(files[0])
+	require.NoError(t, err)
+	defer f.Close()
+	events, err := ReadReplay(f)
+	require.NoError(t, err)
+
+	var types []string
+	for _, ev := range events {
+		types = append(types, ev.Type)
+	}
+	assert.Equal(t, []string{replayGame, replayJoin, replayTurn, replayInvalid, replayTurn, replayGuess, replayTurn, replayGuess, replayWin}, types)
+	assert.Equal(t, "main", events[0].Room)
+	assert.Equal(t, 1, events[0].Game)
+	assert.NotZero(t, events[0].Seed)
+	assert.Equal(t, &RecordedConfig{MaxPlayers: 1, CodeLength: 4, Difficulty: game.DifficultyMedium, TurnTimeSeconds: 30}, events[0].Config)
+	assert.Equal(t, ReplayEvent{Type: replayJoin, At: events[1].At, Player: "alice", Seat: 1}, events[1])
+	assert.Equal(t, "12", events[3].Input)
+	assert.Equal(t, 0, events[5].Guess.Guess)
+	win := events[8]
+	assert.Equal(t, "alice", win.Player)
+	require.NotNil(t, win.Secret)
+	assert.Equal(t, *win.Secret, events[7].Guess.Guess)
+	assert.True(t, game.VerifyCommitment(events[0].Commitment, win.Salt, *win.Secret, 4))
+}
+
+func TestPlayReplay_KeepsTheTiming(t *testing.T) {
+	secret := 1234
+	events := []ReplayEvent{
+		{Type: replayGame, Version: replayVersion, Room: "main", Game: 2, Config: &RecordedConfig{MaxPlayers: 2, CodeLength: 4, Difficulty: game.DifficultyEasy, TurnTimeSeconds: 30}},
+		{Type: replayJoin, Player: "alice", Seat: 1},
+		{Type: replayJoin, At: 10, Player: "bot-1", Seat: 2, Bot: true},
+		{Type: replayTurn, At: 1000, Player: "alice", TimeLimitSeconds: 30},
+		{Type: replayTimeout, At: 31000, Player: "alice"},
+		{Type: replayGuess, At: 32500, Guess: &game.GuessRecord{PlayerName: "bot-1", Guess: 123, CorrectPlace: 1, WrongPlace: 2, Hint: "h"}, Points: 15},
+		{Type: replayWin, At: 40000, Player: "bot-1", Secret: &secret},
+	}
+
+	var out bytes.Buffer
+	var waits []time.Duration
+	playReplay(&out, events, 2, 10*time.Second, func(d time.Duration) { waits = append(waits, d) })
+	assert.Equal(t, []time.Duration{5 * time.Millisecond, 495 * time.Millisecond, 10 * time.Second, 750 * time.Millisecond, 3750 * time.Millisecond}, waits)
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	require.Len(t, lines, 8)
+	assert.Equal(t, "[00:00.0] Replay of game 2 in room main", lines[0])
+	assert.Equal(t, "[00:00.0] bot-1 joined (seat 2, bot)", lines[3])
+	assert.Equal(t, "[00:01.0] alice's turn (30s)", lines[4])
+	assert.Equal(t, "[00:31.0] alice ran out of time", lines[5])
+	assert.Equal(t, "[00:32.5] bot-1 guessed 0123: correctly placed 1, wrongly placed 2, hint: h (+15 points)", lines[6])
+	assert.Equal(t, "[00:40.0] bot-1 won! The secret was 1234", lines[7])
+}
+
+func TestRunReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "game.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte(
+		`{"type":"game","at":0,"version":1,"room":"main","game":1,"config":{"codeLength":4}}`+"\n"+
+			`{"type":"end","at":5000,"reason":"abandoned","secret":42}`+"\n"), 0o600))
+
+	var out bytes.Buffer
+	require.NoError(t, RunReplay([]string{"-speed", "0", path}, &out))
+	assert.Contains(t, out.String(), "[00:05.0] Game over (abandoned). The secret was 0042\n")
+
+	assert.Error(t, RunReplay([]string{path, "extra"}, &out))
+	assert.Error(t, RunReplay([]string{"-speed", "-1", path}, &out))
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"type":"turn","at":0}`+"\n"), 0o600))
+	assert.ErrorContains(t, RunReplay([]string{path}, &out), "not a version 1 replay")
+}
@@ -0,0 +1,891 @@
+package netpkg
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"code_breaker/internal/game"
+)
+
+type eventKind int
+
+const (
+	eventJoin eventKind = iota
+	eventInput
+	eventLeave
+	eventWatch
+	eventReveal
+	eventCall
+)
+
+// roomEvent is everything that can happen to a room from the outside.
+// Events are processed one at a time by the room goroutine, which owns all game state.
+type roomEvent struct {
+	kind   eventKind
+	conn   *clientConn
+	token  string  // eventJoin
+	player *Player // eventInput, eventLeave
+	line   string  // eventInput
+	game   int     // eventReveal: the game whose secret is due
+	call   func() error
+	result chan error // eventCall: receives what call returned
+}
+
+// Room seats players and runs consecutive games between them.
+// Every room has its own settings, state and analytics.
+type Room struct {
+	name      string
+	cfg       game.Config
+	code      game.CodeSpec
+	lobby     *Lobby
+	rng       *rand.Rand
+	events    chan roomEvent
+	done      chan struct{} // closed when the room is closed
+	analytics *Analytics
+	bots      int // seats reserved for bots
+
+	// mu guards cfg, players, spectators and started for readers outside the room
+	// goroutine; the room goroutine is their only writer.
+	mu         sync.Mutex
+	players    []*Player
+	spectators []*Player
+	sessions   map[string]*Player
+	started    bool
+
+	nextCfg         *game.Config // settings changed during a game, applied when the next one starts
+	turnInterrupted bool         // the current turn ended early, e.g. its player was kicked
+	closing         bool         // the room goroutine exits after the current event
+	draining        bool         // the server is shutting down; the room closes when the current turn ends
+
+	gameNumber          int
+	gameStarted         time.Time
+	gameOver            bool // the current game is over and recorded
+	secret              int
+	secretRevealed      bool
+	salt                string // of the commitment to secret, revealed when the game is won
+	history             []game.GuessRecord
+	gamePlayers         []RecordedPlayer // seated when the current game started, kicked players included
+	currentTurn         int
+	turnDeadline        time.Time
+	recovering          bool
+	consecutiveTimeouts int
+	currentGameGuesses  int
+	gameTurns           int // turns human players took in the current game, for the records
+	gameTimeouts        int
+	gameRecoveries      int
+	matchGame           int            // scoring mode: number of the current game in its match
+	gameScores          map[string]int // scoring mode: points by nickname in the current game
+	matchScores         map[string]int // and in the current match
+	seed                int64          // seeds rng for the current game, recorded in its replay
+	replay              *replayWriter  // nil when the current game is not recorded
+}
+
+func newRoom(name string, cfg game.Config, lobby *Lobby) *Room {
+	return &Room{
+		name:      name,
+		cfg:       cfg,
+		code:      game.NewCodeSpec(cfg.CodeLength),
+		lobby:     lobby,
+		rng:       rand.New(rand.NewSource(time.Now().UnixNano())),
+		events:    make(chan roomEvent),
+		done:      make(chan struct{}),
+		sessions:  make(map[string]*Player),
+		analytics: summarize(lobby.games.Records(name)),
+	}
+}
+
+const roomHelp = "Commands: /say <message> | /mute <name> | /unmute <name> | /leaderboard [difficulty] | /notation [last]\n"
+
+// botThinkTime is how long a bot takes for its turn.
+const botThinkTime = time.Second
+
+// seatBots fills the seats reserved for bots once every human has joined.
+func (r *Room) seatBots() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := 0; i < r.bots; i++ {
+		id := len(r.players) + 1
+		r.players = append(r.players, &Player{id: id, name: fmt.Sprintf("bot-%d", id), bot: true})
+	}
+}
+
+// Join hands a connection to the room. A non-empty token reclaims an existing seat.
+func (r *Room) Join(conn *clientConn, token string) {
+	if !r.post(roomEvent{kind: eventJoin, conn: conn, token: token}) {
+		r.bounce(conn)
+	}
+}
+
+// Watch hands a connection to the room as a spectator.
+func (r *Room) Watch(conn *clientConn) {
+	if !r.post(roomEvent{kind: eventWatch, conn: conn}) {
+		r.bounce(conn)
+	}
+}
+
+// bounce sends a connection the room could not take back to the lobby.
+func (r *Room) bounce(conn *clientConn) {
+	conn.send(game.INFO, fmt.Sprintf("Room %s is closed. Back in the lobby.\n", r.name))
+	r.lobby.sendRooms(conn)
+	go r.lobby.Serve(conn)
+}
+
+// post hands ev to the room goroutine; it reports false once the room is closed.
+func (r *Room) post(ev roomEvent) bool {
+	select {
+	case r.events <- ev:
+		return true
+	case <-r.done:
+		return false
+	}
+}
+
+// do runs fn on the room goroutine, where it may use all game state, and returns its error.
+func (r *Room) do(fn func() error) error {
+	result := make(chan error, 1)
+	if !r.post(roomEvent{kind: eventCall, call: fn, result: result}) {
+		return fmt.Errorf("room %s: %w", r.name, errRoomClosed)
+	}
+	return <-result
+}
+
+var errRoomClosed = errors.New("room is closed")
+
+// Info describes the room for the lobby listing.
+func (r *Room) Info() game.RoomInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return game.RoomInfo{
+		Name:            r.name,
+		Players:         len(r.players),
+		MaxPlayers:      r.cfg.MaxPlayers,
+		CodeLength:      r.cfg.CodeLength,
+		Difficulty:      r.cfg.Difficulty,
+		TurnTimeSeconds: r.cfg.TurnTimeSeconds,
+		Spectators:      len(r.spectators),
+		Started:         r.started,
+		Scoring:         r.cfg.Scoring,
+		MatchGames:      matchGames(r.cfg),
+	}
+}
+
+// Run waits for the table to fill up and then plays games forever.
+func (r *Room) Run() {
+	for len(r.players) < r.cfg.MaxPlayers-r.bots {
+		r.nextInput(nil, true)
+	}
+	r.seatBots()
+	r.mu.Lock()
+	r.started = true
+	r.mu.Unlock()
+
+	r.broadcast(game.INFO, "All players connected. Game starting now!\n")
+	for _, p := range r.players {
+		if p.bot {
+			r.broadcast(game.INFO, fmt.Sprintf("%s is a bot.\n", p.name))
+		}
+	}
+	r.currentTurn = r.rng.Intn(len(r.players))
+
+	for {
+		r.playGame()
+	}
+}
+
+func (r *Room) playGame() {
+	settingsChanged := r.nextCfg != nil
+	if settingsChanged {
+		r.mu.Lock()
+		r.cfg = *r.nextCfg
+		r.mu.Unlock()
+		r.code = game.NewCodeSpec(r.cfg.CodeLength)
+		r.nextCfg = nil
+	}
+	r.gameNumber++
+	r.gameStarted = time.Now()
+	r.seed = r.gameStarted.UnixNano()
+	r.rng.Seed(r.seed)
+	r.gameOver = false
+	r.secret = r.code.GenerateSecret(r.cfg.Difficulty)
+	r.salt = newCommitmentSalt()
+	r.secretRevealed = false
+	r.logger().Info("game started", "difficulty", r.cfg.Difficulty, "code_length", r.cfg.CodeLength)
+	r.logger().Debug("new secret", "secret", secret(r.secret))
+	r.history = nil
+	r.gamePlayers = nil
+	for _, p := range r.players {
+		r.gamePlayers = append(r.gamePlayers, RecordedPlayer{Name: p.name, Bot: p.bot})
+	}
+	r.currentGameGuesses = 0
+	r.gameTurns, r.gameTimeouts, r.gameRecoveries = 0, 0, 0
+	r.startScoring(settingsChanged)
+	r.startReplay()
+	r.lobby.metrics.gamesStarted.inc(r.metricLabels()...)
+
+	started := game.Message{Type: game.NEWGAME, Text: "New game started!\n", Commitment: r.commitment(), CodeLength: r.cfg.CodeLength}
+	if r.cfg.Scoring {
+		started.Text = fmt.Sprintf("New game started! Game %d of %d of the match.\n", r.matchGame, r.cfg.MatchGames)
+	}
+	started.Text += fmt.Sprintf("Secret commitment: %s\n", started.Commitment)
+	r.broadcastMessage(started)
+	r.scheduleSecretReveal()
+
+	for {
+		won := r.playTurn()
+		if r.draining {
+			r.closeForShutdown()
+			runtime.Goexit()
+		}
+		if won {
+			return
+		}
+	}
+}
+
+// playTurn gives the current player one turn and reports whether the game was won.
+func (r *Room) playTurn() bool {
+	currentPlayer := r.players[r.currentTurn]
+
+	var timeout <-chan time.Time
+	r.turnDeadline = time.Time{}
+	if r.cfg.MaxPlayers > 1 && !currentPlayer.bot {
+		turnTime := time.Second * time.Duration(r.cfg.TurnTimeSeconds)
+		r.turnDeadline = time.Now().Add(turnTime)
+		timer := time.NewTimer(turnTime)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	r.notifyTurns(currentPlayer)
+	limit := 0
+	if !r.turnDeadline.IsZero() {
+		limit = r.cfg.TurnTimeSeconds
+	}
+	r.recordEvent(ReplayEvent{Type: replayTurn, Player: currentPlayer.name, TimeLimitSeconds: limit})
+	if currentPlayer.bot {
+		return r.playBotTurn(currentPlayer)
+	}
+
+	turnStart := time.Now()
+	for {
+		p, line, ok := r.nextInput(timeout, false)
+		if r.turnInterrupted {
+			r.turnInterrupted = false
+			return false
+		}
+		if !ok {
+			r.lobby.metrics.turnDuration.observeDuration(time.Since(turnStart), r.metricLabels()...)
+			r.gameTurns++
+			return r.handleTimeout(currentPlayer)
+		}
+		if p != currentPlayer {
+			// input out of turn is dropped
+			continue
+		}
+		r.lobby.metrics.turnDuration.observeDuration(time.Since(turnStart), r.metricLabels()...)
+		r.gameTurns++
+
+		numGuess, err := r.code.ValidateGuess(line)
+		if err != nil {
+			r.rejectGuess(p, line, err)
+			return false
+		}
+
+		r.consecutiveTimeouts = 0
+		return r.evaluateGuess(p, numGuess)
+	}
+}
+
+// playBotTurn lets a bot guess a code consistent with the feedback so far.
+func (r *Room) playBotTurn(bot *Player) bool {
+	for !r.humanConnected() {
+		// bots do not play on their own
+		r.nextInput(nil, true)
+	}
+	r.pause(botThinkTime)
+	r.consecutiveTimeouts = 0
+	return r.evaluateGuess(bot, r.code.SuggestGuess(r.history, r.rng))
+}
+
+func (r *Room) handleTimeout(currentPlayer *Player) bool {
+	r.recordEvent(ReplayEvent{Type: replayTimeout, Player: currentPlayer.name})
+	r.addPoints(currentPlayer, penaltyTimeout)
+	r.broadcastScored(game.Message{Type: game.TIMEOUT, Text: fmt.Sprintf("%s ran out of time and forfeited the turn!\n", currentPlayer.name)})
+	r.lobby.metrics.timeouts.inc(r.metricLabels()...)
+	r.gameTimeouts++
+	r.consecutiveTimeouts++
+	if r.consecutiveTimeouts >= len(r.players) {
+		return r.handleRecovery()
+	}
+	r.currentTurn = (r.currentTurn + 1) % len(r.players)
+	return false
+}
+
+// handleRecovery waits, without a time limit, for ANY player to send a guess.
+func (r *Room) handleRecovery() bool {
+	r.recovering = true
+	r.turnDeadline = time.Time{}
+	r.lobby.metrics.recoveries.inc(r.metricLabels()...)
+	r.gameRecoveries++
+	r.recordEvent(ReplayEvent{Type: replayRecovery})
+	r.broadcast(game.RECOVERY, "All players timed out. Waiting for ANY player to resume...\n")
+
+	resumePlayer, guess, _ := r.nextInput(nil, false)
+	r.recovering = false
+	r.consecutiveTimeouts = 0
+	if r.turnInterrupted {
+		r.turnInterrupted = false
+		return false
+	}
+	r.gameTurns++
+
+	numGuess, err := r.code.ValidateGuess(guess)
+	if err != nil {
+		r.rejectGuess(resumePlayer, guess, err)
+		r.currentTurn = resumePlayer.id - 1
+		return false
+	}
+	return r.evaluateGuess(resumePlayer, numGuess)
+}
+
+// evaluateGuess scores a valid guess, passes the turn on and reports whether it won the game.
+func (r *Room) evaluateGuess(p *Player, numGuess int) bool {
+	r.currentGameGuesses++
+	r.lobby.metrics.guesses.inc(r.metricLabels()...)
+	feedback := r.code.Feedback(r.secret, numGuess, r.rng)
+	record := game.GuessRecord{
+		Player:       r.gameSeat(p),
+		PlayerName:   p.name,
+		Guess:        numGuess,
+		CorrectPlace: feedback.CorrectPlace,
+		WrongPlace:   feedback.WrongPlace,
+		Hint:         feedback.Hint,
+	}
+	points := guessPoints(r.history, record)
+	r.addPoints(p, points)
+	if !r.cfg.Scoring {
+		points = 0
+	}
+	r.recordEvent(ReplayEvent{Type: replayGuess, Guess: &record, Points: points})
+	r.history = append(r.history, record)
+	r.currentTurn = p.id % len(r.players)
+
+	if feedback.CorrectPlace == r.cfg.CodeLength {
+		r.handleWin(p, record)
+		r.pause(3 * time.Second)
+		return true
+	}
+
+	msg := fmt.Sprintf(
+		ColorBlue+"player: %s\n"+ColorCyan+"Number guessed: %d\n"+ColorGreen+"Correctly placed: %d\n"+ColorYellow+"Wrongly placed: %d\n"+ColorPurple+"Hint: %s\n"+ColorReset,
+		p.name, numGuess, feedback.CorrectPlace, feedback.WrongPlace, feedback.Hint,
+	)
+	r.broadcastScored(game.Message{Type: game.RESULT, Text: game.GenerateTimestampPrefix() + msg, Guess: &record})
+	return false
+}
+
+func (r *Room) handleWin(winner *Player, guess game.GuessRecord) {
+	winMsg := fmt.Sprintf("%s won! Secret was %d (commitment salt %s)\n", winner.name, r.secret, r.salt)
+	if r.cfg.Scoring {
+		r.addPoints(winner, pointsSolved)
+		winMsg += fmt.Sprintf("%s earns %d points for solving it.\n", winner.name, pointsSolved)
+	}
+
+	r.recordGame(winner)
+	r.lobby.metrics.gamesWon.inc(r.metricLabels()...)
+	r.lobby.metrics.guessesToWin.observe(float64(r.currentGameGuesses), r.metricLabels()...)
+
+	r.broadcastScored(game.Message{Type: game.WIN, Text: game.GenerateTimestampPrefix() + winMsg, Guess: &guess,
+		Secret: r.secret, Salt: r.salt, CodeLength: r.cfg.CodeLength})
+	if r.matchOver() {
+		r.announceMatch()
+		r.broadcast(game.NEWGAME, "New match starting in 3 seconds...\n")
+	} else {
+		r.broadcast(game.NEWGAME, "New game starting in 3 seconds...\n")
+	}
+	r.logger().Info("game won", "player", winner.id, "name", winner.name, "guesses", r.currentGameGuesses)
+	logAnalytics(r.logger(), r.analytics)
+	logStats(r.logger(), computeStats(r.lobby.games.Records(r.name), RecordFilter{}))
+}
+
+// gameSeat is p's seat when the current game started, which kicks renumbering the seats do not change.
+func (r *Room) gameSeat(p *Player) int {
+	for i, seated := range r.gamePlayers {
+		if seated.Name == p.name {
+			return i + 1
+		}
+	}
+	return p.id
+}
+
+// recordGame stores the current game, won by winner or abandoned if winner is nil, and counts it in the analytics.
+func (r *Room) recordGame(winner *Player) {
+	r.gameOver = true
+	now := time.Now()
+	rec := GameRecord{
+		Room:            r.name,
+		Game:            r.gameNumber,
+		Config:          *recordedConfig(r.cfg),
+		Secret:          r.secret,
+		Salt:            r.salt,
+		Guesses:         append([]game.GuessRecord{}, r.history...),
+		Started:         r.gameStarted.UTC(),
+		Ended:           now.UTC(),
+		DurationSeconds: now.Sub(r.gameStarted).Seconds(),
+		Turns:           r.gameTurns,
+		Timeouts:        r.gameTimeouts,
+		Recoveries:      r.gameRecoveries,
+	}
+	if r.cfg.Scoring {
+		rec.Scores = make(map[string]int, len(r.gameScores))
+		for name, points := range r.gameScores {
+			rec.Scores[name] = points
+		}
+		rec.MatchGame = r.matchGame
+	}
+	rec.Players = append(rec.Players, r.gamePlayers...)
+	if winner != nil {
+		rec.Winner = winner.name
+	}
+	if err := r.lobby.games.Append(&rec); err != nil {
+		r.logger().Error("error recording game", "err", err)
+	}
+	r.analytics.add(rec)
+	r.lobby.ratings.add(rec)
+	r.finishReplay(winner)
+}
+
+// nextInput processes room events until a seated player sends a line, timeout fires
+// or the current turn is interrupted. Joins and disconnects are handled on the way.
+// With untilJoin set it also returns after every join and call, which is what the
+// pre-game lobby waits for.
+func (r *Room) nextInput(timeout <-chan time.Time, untilJoin bool) (*Player, string, bool) {
+	for {
+		select {
+		case ev := <-r.events:
+			switch ev.kind {
+			case eventJoin:
+				r.handleJoin(ev)
+				if untilJoin {
+					return nil, "", false
+				}
+			case eventLeave:
+				r.handleLeave(ev)
+			case eventWatch:
+				r.handleWatch(ev)
+			case eventReveal:
+				if ev.game == r.gameNumber {
+					r.revealSecret()
+				}
+			case eventCall:
+				ev.result <- ev.call()
+				if r.closing {
+					// the room is closed; end its goroutine wherever the game is
+					runtime.Goexit()
+				}
+				if untilJoin || r.turnInterrupted {
+					return nil, "", false
+				}
+			case eventInput:
+				if !ev.player.isConn(ev.conn) {
+					continue
+				}
+				if strings.HasPrefix(ev.line, "/") {
+					r.handleCommand(ev.player, ev.line)
+					continue
+				}
+				if ev.player.spectator {
+					// spectators never guess
+					continue
+				}
+				return ev.player, ev.line, true
+			}
+		case <-timeout:
+			return nil, "", false
+		}
+	}
+}
+
+// pause keeps serving joins and disconnects for d while ignoring guesses.
+func (r *Room) pause(d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	for {
+		_, _, ok := r.nextInput(timer.C, false)
+		if r.turnInterrupted {
+			// there is no turn in progress to end
+			r.turnInterrupted = false
+			continue
+		}
+		if !ok {
+			return
+		}
+	}
+}
+
+func (r *Room) handleJoin(ev roomEvent) {
+	if p, ok := r.sessions[ev.token]; ok && ev.token != "" {
+		r.reconnect(p, ev.conn)
+		return
+	}
+	if p := r.seat(ev.conn.name); p != nil && p.account && ev.conn.account {
+		// logging in to the account is as good as the session token
+		r.reconnect(p, ev.conn)
+		return
+	}
+	if r.started || len(r.players) >= r.cfg.MaxPlayers-r.bots {
+		ev.conn.send(game.INFO, fmt.Sprintf("Room %s is full. Back in the lobby.\n", r.name))
+		r.lobby.sendRooms(ev.conn)
+		go r.lobby.Serve(ev.conn)
+		return
+	}
+
+	player := &Player{
+		id:      len(r.players) + 1,
+		name:    ev.conn.name,
+		token:   newSessionToken(),
+		account: ev.conn.account,
+	}
+	r.mu.Lock()
+	r.players = append(r.players, player)
+	r.mu.Unlock()
+	r.sessions[player.token] = player
+	r.lobby.registerSession(player.token, r)
+	r.attach(player, ev.conn)
+
+	r.logger().Info("player connected", "player", player.id, "name", player.name)
+	r.sendSession(player)
+	player.send(game.INFO, fmt.Sprintf("Welcome %s to room %s! Waiting for others...\n", player.name, r.name))
+}
+
+func (r *Room) reconnect(p *Player, conn *clientConn) {
+	r.broadcast(game.INFO, fmt.Sprintf("%s reconnected.\n", p.name))
+	r.attach(p, conn)
+	r.logger().Info("player reconnected", "player", p.id, "name", p.name)
+	r.recordEvent(ReplayEvent{Type: replayRejoin, Player: p.name})
+	r.sendSession(p)
+	if !r.started {
+		p.send(game.INFO, fmt.Sprintf("Welcome back %s! Waiting for others...\n", p.name))
+		return
+	}
+
+	snapshot := r.snapshot(p)
+	p.sendMessage(game.Message{Type: game.SNAPSHOT, Text: formatSnapshot(snapshot), Snapshot: &snapshot})
+
+	switch {
+	case r.recovering:
+		p.send(game.RECOVERY, "All players timed out. Waiting for ANY player to resume...\n")
+	case r.players[r.currentTurn] == p:
+		p.send(game.TURN, "Your turn!\n")
+	default:
+		p.send(game.WAIT, fmt.Sprintf("Waiting for %s...\n", r.players[r.currentTurn].name))
+	}
+}
+
+func (r *Room) handleLeave(ev roomEvent) {
+	if !ev.player.detach(ev.conn) {
+		// an old connection replaced by a reconnect
+		return
+	}
+	r.lobby.logout(ev.conn)
+	if ev.player.spectator {
+		r.removeSpectator(ev.player)
+		r.lobby.releaseName(ev.player.name)
+		return
+	}
+	r.logger().Info("player disconnected", "player", ev.player.id, "name", ev.player.name)
+	r.recordEvent(ReplayEvent{Type: replayLeave, Player: ev.player.name})
+	r.broadcast(game.INFO, fmt.Sprintf("%s disconnected. The seat is held until they reconnect.\n", ev.player.name))
+}
+
+// seat finds the seat taken with the given name, ignoring case.
+func (r *Room) seat(name string) *Player {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, p := range r.players {
+		if strings.EqualFold(p.name, name) {
+			return p
+		}
+	}
+	return nil
+}
+
+func (r *Room) humanConnected() bool {
+	for _, p := range r.players {
+		if !p.bot && p.connected() {
+			return true
+		}
+	}
+	return false
+}
+
+// handleCommand answers a slash command sent from inside the room.
+func (r *Room) handleCommand(p *Player, line string) {
+	cmd, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+	switch cmd {
+	case game.CmdSay:
+		r.chat(p, arg)
+		return
+	case game.CmdMute, game.CmdUnmute:
+		r.setMuted(p, arg, cmd == game.CmdMute)
+		return
+	case game.CmdLeaderboard:
+		sendLeaderboard(r.lobby, p.sendMessage, arg, r.cfg.Difficulty)
+		return
+	case game.CmdNotation:
+		r.sendNotation(p, arg)
+		return
+	}
+
+	role := "watching"
+	if !p.spectator {
+		role = "playing"
+	}
+	p.send(game.INFO, fmt.Sprintf("You are %s (%s) in room %s. %s", p.name, role, r.name, roomHelp))
+}
+
+func (r *Room) handleWatch(ev roomEvent) {
+	spectator := &Player{name: ev.conn.name, spectator: true}
+	r.mu.Lock()
+	r.spectators = append(r.spectators, spectator)
+	r.mu.Unlock()
+	r.attach(spectator, ev.conn)
+
+	r.logger().Info("spectator joined", "name", spectator.name, "spectators", len(r.spectators))
+	spectator.sendMessage(game.Message{Type: game.WATCHING, Text: fmt.Sprintf("You are watching room %s.\n", r.name), Room: r.name})
+	if !r.started {
+		spectator.send(game.INFO, fmt.Sprintf("Waiting for players (%d/%d)...\n", len(r.players), r.cfg.MaxPlayers))
+		return
+	}
+	snapshot := r.snapshot(spectator)
+	spectator.sendMessage(game.Message{Type: game.SNAPSHOT, Text: formatSnapshot(snapshot), Snapshot: &snapshot})
+}
+
+func (r *Room) removeSpectator(spectator *Player) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, s := range r.spectators {
+		if s == spectator {
+			r.spectators = append(r.spectators[:i], r.spectators[i+1:]...)
+			return
+		}
+	}
+}
+
+// scheduleSecretReveal arranges for spectators to see the secret of the current game, if configured.
+func (r *Room) scheduleSecretReveal() {
+	if r.cfg.SpectatorSecretDelaySeconds < 0 {
+		return
+	}
+	gameNumber := r.gameNumber
+	time.AfterFunc(time.Duration(r.cfg.SpectatorSecretDelaySeconds)*time.Second, func() {
+		r.post(roomEvent{kind: eventReveal, game: gameNumber})
+	})
+}
+
+func (r *Room) revealSecret() {
+	r.secretRevealed = true
+	for _, s := range r.spectators {
+		s.send(game.SECRET, fmt.Sprintf("Spectators only: the secret is %d\n", r.secret))
+	}
+}
+
+// chat relays a message to everyone in the room who has not muted its sender.
+func (r *Room) chat(from *Player, text string) {
+	if text == "" {
+		from.send(game.INFO, "Usage: /say <message>\n")
+		return
+	}
+	if n := utf8.RuneCountInString(text); n > r.cfg.ChatMaxLength {
+		from.send(game.INFO, fmt.Sprintf("Chat message is too long (%d characters, at most %d).\n", n, r.cfg.ChatMaxLength))
+		return
+	}
+
+	msg := game.Message{Type: game.CHAT, Text: fmt.Sprintf("[%s]: %s\n", from.name, text), From: from.name}
+	for _, group := range [][]*Player{r.players, r.spectators} {
+		for _, to := range group {
+			if !to.muted[from] {
+				to.sendMessage(msg)
+			}
+		}
+	}
+}
+
+// setMuted mutes or unmutes the chat of the named player or spectator for p only.
+func (r *Room) setMuted(p *Player, name string, mute bool) {
+	target := r.findByName(name)
+	switch {
+	case name == "":
+		p.send(game.INFO, "Usage: /mute <name> or /unmute <name>\n")
+		return
+	case target == nil:
+		p.send(game.INFO, fmt.Sprintf("Nobody called %s is in this room.\n", name))
+		return
+	case target == p:
+		p.send(game.INFO, "You cannot mute yourself.\n")
+		return
+	}
+
+	if p.muted == nil {
+		p.muted = make(map[*Player]bool)
+	}
+	if mute {
+		p.muted[target] = true
+		p.send(game.INFO, fmt.Sprintf("%s is muted.\n", target.name))
+	} else {
+		delete(p.muted, target)
+		p.send(game.INFO, fmt.Sprintf("%s is unmuted.\n", target.name))
+	}
+}
+
+// findByName looks up a player or spectator by name, ignoring case.
+// A bare number that is nobody's name is taken as a player number.
+func (r *Room) findByName(name string) *Player {
+	for _, group := range [][]*Player{r.players, r.spectators} {
+		for _, p := range group {
+			if strings.EqualFold(p.name, name) {
+				return p
+			}
+		}
+	}
+	if id, err := strconv.Atoi(name); err == nil && id >= 1 && id <= len(r.players) {
+		return r.players[id-1]
+	}
+	return nil
+}
+
+// attach binds conn to p and starts feeding its input into the room.
+func (r *Room) attach(p *Player, conn *clientConn) {
+	p.attach(conn)
+	go r.readInput(p, conn)
+}
+
+func (r *Room) readInput(p *Player, conn *clientConn) {
+	for {
+		line, err := conn.reader.ReadString('\n')
+		if err != nil {
+			r.post(roomEvent{kind: eventLeave, conn: conn, player: p})
+			return
+		}
+		if !r.post(roomEvent{kind: eventInput, conn: conn, player: p, line: strings.TrimSpace(line)}) {
+			return
+		}
+	}
+}
+
+func (r *Room) sendSession(p *Player) {
+	p.sendMessage(game.Message{
+		Type:  game.SESSION,
+		Text:  fmt.Sprintf("Session token: %s (use it to rejoin as %s)\n", p.token, p.name),
+		Token: p.token,
+	})
+}
+
+// timeLeft is the number of seconds left in the current turn, 0 if it has no limit.
+func (r *Room) timeLeft() int {
+	if r.turnDeadline.IsZero() {
+		return 0
+	}
+	timeLeft := int(time.Until(r.turnDeadline).Round(time.Second).Seconds())
+	if timeLeft < 0 {
+		return 0
+	}
+	return timeLeft
+}
+
+func (r *Room) snapshot(p *Player) game.Snapshot {
+	history := make([]game.GuessRecord, len(r.history))
+	copy(history, r.history)
+
+	snapshot := game.Snapshot{
+		PlayerID:        p.id,
+		PlayerName:      p.name,
+		CurrentTurn:     r.players[r.currentTurn].id,
+		CurrentTurnName: r.players[r.currentTurn].name,
+		TimeLeftSeconds: r.timeLeft(),
+		History:         history,
+	}
+	if p.spectator && r.secretRevealed {
+		snapshot.Secret = r.secret
+	}
+	snapshot.Scores = r.scores()
+	snapshot.Commitment = r.commitment()
+	return snapshot
+}
+
+// commitment returns the commitment to the secret of the current game.
+func (r *Room) commitment() string {
+	return game.CommitSecret(r.secret, r.cfg.CodeLength, r.salt)
+}
+
+func formatSnapshot(s game.Snapshot) string {
+	var b strings.Builder
+	b.WriteString("====== GAME IN PROGRESS ======\n")
+	if len(s.History) == 0 {
+		b.WriteString("No guesses yet.\n")
+	}
+	for i, g := range s.History {
+		fmt.Fprintf(&b, "#%d player: %s | guess: %d | correctly placed: %d | wrongly placed: %d | hint: %s\n",
+			i+1, g.PlayerName, g.Guess, g.CorrectPlace, g.WrongPlace, g.Hint)
+	}
+	fmt.Fprintf(&b, "Current turn: %s", s.CurrentTurnName)
+	if s.TimeLeftSeconds > 0 {
+		fmt.Fprintf(&b, " (%ds left)", s.TimeLeftSeconds)
+	}
+	if s.Secret != 0 {
+		fmt.Fprintf(&b, "\nSecret: %d", s.Secret)
+	}
+	if s.Scores != nil {
+		b.WriteString("\n" + strings.TrimSuffix(formatScores(s.Scores), "\n"))
+	}
+	b.WriteString("\n==============================\n")
+	return b.String()
+}
+
+func (r *Room) notifyTurns(currentPlayer *Player) {
+	turn := game.Message{Player: currentPlayer.name, TimeLeftSeconds: r.timeLeft()}
+	for _, p := range r.players {
+		if p == currentPlayer {
+			turn.Type, turn.Text = game.TURN, "Your turn!\n"
+		} else {
+			turn.Type, turn.Text = game.WAIT, fmt.Sprintf("Waiting for %s...\n", currentPlayer.name)
+		}
+		p.sendMessage(turn)
+	}
+	turn.Type, turn.Text = game.WAIT, fmt.Sprintf("%s's turn.\n", currentPlayer.name)
+	for _, s := range r.spectators {
+		s.sendMessage(turn)
+	}
+}
+
+// metricLabels are the values of the game labels of the room's metrics.
+func (r *Room) metricLabels() []string {
+	return gameLabelValues(r.cfg)
+}
+
+// logger logs with the room and the current game.
+func (r *Room) logger() *Logger {
+	return serverLog.With("room", r.name, "game", r.gameNumber)
+}
+
+// broadcast sends to every player and spectator.
+func (r *Room) broadcast(msgType game.MessageType, msg string) {
+	r.broadcastMessage(game.Message{Type: msgType, Text: msg})
+}
+
+func (r *Room) broadcastMessage(msg game.Message) {
+	for _, p := range r.players {
+		p.sendMessage(msg)
+	}
+	for _, s := range r.spectators {
+		s.sendMessage(msg)
+	}
+}
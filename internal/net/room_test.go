@@ -0,0 +1,352 @@
+package netpkg
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"code_breaker/internal/game"
+)
+
+type testClient struct {
+	t    *testing.T
+	conn net.Conn
+	dec  *json.Decoder
+}
+
+// startTestServer serves a lobby with a DefaultRoom running cfg.
+func startTestServer(t *testing.T, cfg game.Config) string {
+	return serveTestLobby(t, newTestLobby(t, cfg))
+}
+
+// newTestLobby creates a lobby with a DefaultRoom running cfg.
+func newTestLobby(t *testing.T, cfg game.Config) *Lobby {
+	lobby := NewLobby(cfg, game.MatchmakingConfig{WaitSeconds: 1, FillWithBots: true}, nil, nil)
+	_, err := lobby.CreateRoom(DefaultRoom, cfg)
+	require.NoError(t, err)
+	return lobby
+}
+
+// serveTestLobby accepts TCP connections into lobby and returns the address.
+func serveTestLobby(t *testing.T, lobby *Lobby) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() { _ = serve(listener, lobby) }()
+	return listener.Addr().String()
+}
+
+// dialTestClient connects and sends the given handshake lines.
+func dialTestClient(t *testing.T, addr string, lines ...string) *testClient {
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	c := &testClient{t: t, conn: conn, dec: json.NewDecoder(bufio.NewReader(conn))}
+	for _, line := range lines {
+		c.send(line)
+	}
+	return c
+}
+
+// dialPlayer connects, completes the handshake as name and sends the given lines.
+func dialPlayer(t *testing.T, addr string, name string, lines ...string) *testClient {
+	c := dialTestClient(t, addr, game.CmdHello+" "+name)
+	c.expect(game.LOBBY)
+	for _, line := range lines {
+		c.send(line)
+	}
+	return c
+}
+
+var joinMain = game.CmdJoin + " " + DefaultRoom
+
+// expect reads messages until one of the given type arrives.
+func (c *testClient) expect(msgType game.MessageType) game.Message {
+	_ = c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var msg game.Message
+		require.NoError(c.t, c.dec.Decode(&msg), "waiting for %s", msgType)
+		if msg.Type == msgType {
+			return msg
+		}
+	}
+}
+
+// expectClosed reads messages until the server closes the connection.
+func (c *testClient) expectClosed() {
+	_ = c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var msg game.Message
+		if err := c.dec.Decode(&msg); err != nil {
+			var netErr net.Error
+			require.False(c.t, errors.As(err, &netErr) && netErr.Timeout(), "the connection is still open")
+			return
+		}
+	}
+}
+
+// expectTurnOrWait reads messages until the turn is handed out and reports
+// whether this client got it.
+func (c *testClient) expectTurnOrWait() bool {
+	_ = c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var msg game.Message
+		require.NoError(c.t, c.dec.Decode(&msg))
+		switch msg.Type {
+		case game.TURN:
+			return true
+		case game.WAIT:
+			return false
+		}
+	}
+}
+
+func (c *testClient) send(line string) {
+	_, err := c.conn.Write([]byte(line + "\n"))
+	require.NoError(c.t, err)
+}
+
+func TestRoom_ResumeSessionRestoresSeatAndSnapshot(t *testing.T) {
+	addr := startTestServer(t, testConfig(2))
+
+	p1 := dialPlayer(t, addr, "alice", joinMain)
+	token := p1.expect(game.SESSION).Token
+	require.NotEmpty(t, token)
+
+	p2 := dialPlayer(t, addr, "bob", joinMain)
+	require.NotEqual(t, token, p2.expect(game.SESSION).Token)
+
+	// whoever has the turn makes a guess that can never win, so there is history to restore
+	p1Turn := p1.expectTurnOrWait()
+	p2.expectTurnOrWait()
+	if p1Turn {
+		p1.send("0000")
+	} else {
+		p2.send("0000")
+	}
+	p1.expect(game.RESULT)
+	p1.expectTurnOrWait()
+	_ = p1.conn.Close()
+
+	resumed := dialTestClient(t, addr, game.CmdResume+" "+token)
+	require.Equal(t, token, resumed.expect(game.SESSION).Token)
+
+	snapshot := resumed.expect(game.SNAPSHOT).Snapshot
+	require.NotNil(t, snapshot)
+	require.Equal(t, 1, snapshot.PlayerID)
+	require.Equal(t, "alice", snapshot.PlayerName)
+	require.Len(t, snapshot.History, 1)
+	require.Equal(t, 0, snapshot.History[0].Guess)
+	require.Greater(t, snapshot.TimeLeftSeconds, 0)
+
+	// the resumed seat keeps playing: it gets the turn that follows the first guess
+	require.Equal(t, !p1Turn, resumed.expectTurnOrWait())
+}
+
+func TestRoom_RejectsNewPlayerOnceStarted(t *testing.T) {
+	addr := startTestServer(t, testConfig(1))
+
+	p1 := dialPlayer(t, addr, "alice", joinMain)
+	p1.expect(game.TURN)
+
+	late := dialPlayer(t, addr, "bob", game.CmdResume+" not-a-token")
+	require.Contains(t, late.expect(game.INFO).Text, "Session not found")
+	late.send(joinMain)
+	require.Contains(t, late.expect(game.INFO).Text, "is full")
+
+	// the rejected connection is back in the lobby
+	late.send(game.CmdRooms)
+	rooms := late.expect(game.LOBBY).Rooms
+	require.Len(t, rooms, 1)
+	require.True(t, rooms[0].Started)
+}
+
+func TestRoom_DropsAPlayerThatStopsReading(t *testing.T) {
+	lobby := newTestLobby(t, testConfig(2))
+	room := lobby.room(DefaultRoom)
+	addr := serveTestLobby(t, lobby)
+	alice := dialPlayer(t, addr, "alice", joinMain)
+	alice.expect(game.SESSION)
+
+	// bob reads until he has his seat, then never again
+	server, client := net.Pipe()
+	t.Cleanup(func() { _ = client.Close() })
+	conn := newClientConn(server, 100*time.Millisecond)
+	go lobby.Serve(conn)
+	bob := &testClient{t: t, conn: client, dec: json.NewDecoder(bufio.NewReader(client))}
+	bob.send(game.CmdHello + " bob")
+	bob.expect(game.LOBBY)
+	bob.send(joinMain)
+	bob.expect(game.SESSION)
+
+	alice.expectTurnOrWait()
+	require.Eventually(t, func() bool {
+		connected := true
+		_ = room.do(func() error {
+			connected = room.players[1].connected()
+			return nil
+		})
+		return !connected
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.NoError(t, room.do(func() error { return nil }), "the room keeps serving")
+}
+
+func TestRoom_KeepsServingWhileAClientStopsReading(t *testing.T) {
+	lobby := newTestLobby(t, testConfig(1))
+	room := lobby.room(DefaultRoom)
+	addr := serveTestLobby(t, lobby)
+
+	// carol watches until she is let in, then never reads again, and her writes never time out
+	server, client := net.Pipe()
+	t.Cleanup(func() { _ = client.Close() })
+	conn := newClientConn(server, time.Hour)
+	go lobby.Serve(conn)
+	carol := &testClient{t: t, conn: client, dec: json.NewDecoder(bufio.NewReader(client))}
+	carol.send(game.CmdHello + " carol")
+	carol.expect(game.LOBBY)
+	carol.send(game.CmdWatch + " " + DefaultRoom)
+	carol.expect(game.WATCHING)
+
+	// the room plays on while her messages wait in the queue
+	alice := dialPlayer(t, addr, "alice", joinMain)
+	alice.expect(game.TURN)
+	for i := 0; i < 3; i++ {
+		alice.send("0000")
+		alice.expect(game.RESULT)
+		alice.expect(game.TURN)
+	}
+
+	// until the queue is full, and she is dropped
+	require.NoError(t, room.do(func() error {
+		for i := 0; i < sendQueueSize+1; i++ {
+			room.broadcast(game.INFO, "filler\n")
+		}
+		return nil
+	}))
+	require.Eventually(t, func() bool {
+		watching := 0
+		_ = room.do(func() error {
+			watching = len(room.spectators)
+			return nil
+		})
+		return watching == 0
+	}, 5*time.Second, 20*time.Millisecond)
+}
+
+func TestRoom_SpectatorWatchesWithoutPlaying(t *testing.T) {
+	cfg := testConfig(1)
+	cfg.SpectatorSecretDelaySeconds = 0
+	addr := startTestServer(t, cfg)
+
+	spectator := dialPlayer(t, addr, "carol", game.CmdWatch+" "+DefaultRoom)
+	watching := spectator.expect(game.WATCHING)
+	require.Contains(t, watching.Text, "watching room main")
+	require.Equal(t, DefaultRoom, watching.Room)
+
+	player := dialPlayer(t, addr, "alice", joinMain)
+	player.expect(game.TURN)
+
+	spectator.expect(game.NEWGAME)
+	wait := spectator.expect(game.WAIT)
+	require.Equal(t, "alice's turn.\n", wait.Text)
+	require.Equal(t, "alice", wait.Player)
+	require.Contains(t, spectator.expect(game.SECRET).Text, "the secret is")
+
+	// a spectator's guess is ignored, the player's is broadcast to the spectator
+	spectator.send("0000")
+	player.send("0000")
+	result := spectator.expect(game.RESULT)
+	require.Contains(t, result.Text, "player: alice")
+	require.NotNil(t, result.Guess)
+	require.Equal(t, "alice", result.Guess.PlayerName)
+	require.Equal(t, 0, result.Guess.Guess)
+
+	rooms := dialTestClient(t, addr, game.CmdRooms).expect(game.LOBBY).Rooms
+	require.Equal(t, 1, rooms[0].Players)
+	require.Equal(t, 1, rooms[0].Spectators)
+
+	late := dialPlayer(t, addr, "dave", game.CmdWatch+" "+DefaultRoom)
+	snapshot := late.expect(game.SNAPSHOT).Snapshot
+	require.Equal(t, 0, snapshot.PlayerID)
+	require.Len(t, snapshot.History, 1)
+	require.NotZero(t, snapshot.Secret)
+}
+
+func TestRoom_ChatReachesPlayersAndSpectatorsUnlessMuted(t *testing.T) {
+	addr := startTestServer(t, testConfig(2))
+
+	p1 := dialPlayer(t, addr, "alice", joinMain)
+	p1.expect(game.SESSION)
+	p2 := dialPlayer(t, addr, "bob", joinMain)
+	p2.expect(game.SESSION)
+	spectator := dialPlayer(t, addr, "carol", game.CmdWatch+" "+DefaultRoom)
+	spectator.expect(game.SNAPSHOT)
+
+	// chat works out of turn
+	p1.send(game.CmdSay + " good luck")
+	for _, c := range []*testClient{p1, p2, spectator} {
+		msg := c.expect(game.CHAT)
+		require.Equal(t, "[alice]: good luck\n", msg.Text)
+		require.Equal(t, "alice", msg.From)
+	}
+
+	p1.send(game.CmdSay + " this message is far too long")
+	require.Contains(t, p1.expect(game.INFO).Text, "too long")
+
+	p2.send(game.CmdMute + " CAROL")
+	require.Equal(t, "carol is muted.\n", p2.expect(game.INFO).Text)
+	spectator.send(game.CmdSay + " hi")
+	require.Equal(t, "[carol]: hi\n", p1.expect(game.CHAT).Text)
+	p1.send(game.CmdSay + " hello")
+	// p2 skips the muted message and sees the next one
+	require.Equal(t, "[alice]: hello\n", p2.expect(game.CHAT).Text)
+
+	p2.send(game.CmdUnmute + " carol")
+	require.Equal(t, "carol is unmuted.\n", p2.expect(game.INFO).Text)
+	spectator.send(game.CmdSay + " back")
+	require.Equal(t, "[carol]: back\n", p2.expect(game.CHAT).Text)
+}
+
+func TestRoom_CommitsToTheSecret(t *testing.T) {
+	lobby := newTestLobby(t, testConfig(1))
+	room := lobby.room(DefaultRoom)
+	addr := serveTestLobby(t, lobby)
+
+	alice := dialPlayer(t, addr, "alice", joinMain)
+	started := alice.expect(game.NEWGAME)
+	require.Len(t, started.Commitment, 64)
+	assert.Contains(t, started.Text, started.Commitment)
+	alice.expect(game.TURN)
+	guessSecret(t, room, alice)
+	win := alice.expect(game.WIN)
+	require.NotEmpty(t, win.Salt)
+	assert.Equal(t, win.Guess.Guess, win.Secret)
+	assert.Equal(t, 4, started.CodeLength)
+	assert.Equal(t, 4, win.CodeLength)
+	assert.True(t, game.VerifyCommitment(started.Commitment, win.Salt, win.Secret, 4))
+	assert.Equal(t, "Secret verified against the commitment published when the game started.\n", checkCommitment(started.Commitment, win))
+
+	records := lobby.games.Records(DefaultRoom)
+	require.Len(t, records, 1)
+	assert.Equal(t, win.Salt, records[0].Salt)
+	assert.Equal(t, started.Commitment, recordNotation(records[0]).Commitment)
+}
+
+func TestRoom_FindByNamePrefersNamesToSeatNumbers(t *testing.T) {
+	named2 := &Player{id: 1, name: "2"}
+	bob := &Player{id: 2, name: "bob"}
+	watcher := &Player{name: "Watcher", spectator: true}
+	r := &Room{players: []*Player{named2, bob}, spectators: []*Player{watcher}}
+
+	assert.Same(t, named2, r.findByName("2"), "the player named 2, not seat 2")
+	assert.Same(t, named2, r.findByName("1"))
+	assert.Same(t, bob, r.findByName("BOB"))
+	assert.Same(t, watcher, r.findByName("watcher"))
+	assert.Nil(t, r.findByName("3"))
+}
@@ -0,0 +1,142 @@
+package netpkg
+
+import (
+	"fmt"
+	"strings"
+
+	"code_breaker/internal/game"
+)
+
+// Points of the scoring mode. A guess earns points for the information it adds to the game:
+// for each correct digit and each correctly placed digit beyond the most any earlier guess had.
+const (
+	pointsNewDigit = 5
+	pointsNewPlace = 10
+	pointsSolved   = 50
+	penaltyTimeout = -10
+	penaltyInvalid = -5
+)
+
+// matchGames returns the games of a match with cfg, 0 without scoring.
+func matchGames(cfg game.Config) int {
+	if !cfg.Scoring {
+		return 0
+	}
+	return cfg.MatchGames
+}
+
+// guessPoints scores a guess against the guesses made before it in the game.
+func guessPoints(history []game.GuessRecord, guess game.GuessRecord) int {
+	bestPlaced, bestFound := 0, 0
+	for _, g := range history {
+		if g.CorrectPlace > bestPlaced {
+			bestPlaced = g.CorrectPlace
+		}
+		if found := g.CorrectPlace + g.WrongPlace; found > bestFound {
+			bestFound = found
+		}
+	}
+	points := 0
+	if guess.CorrectPlace > bestPlaced {
+		points += pointsNewPlace * (guess.CorrectPlace - bestPlaced)
+	}
+	if found := guess.CorrectPlace + guess.WrongPlace; found > bestFound {
+		points += pointsNewDigit * (found - bestFound)
+	}
+	return points
+}
+
+// startScoring resets the game scores, and the match scores when a new match begins:
+// after the last game of a match or when the room's settings changed.
+func (r *Room) startScoring(settingsChanged bool) {
+	if !r.cfg.Scoring {
+		r.matchGame = 0
+		return
+	}
+	if settingsChanged || r.matchGame == 0 || r.matchGame >= r.cfg.MatchGames {
+		r.matchGame = 0
+		r.matchScores = make(map[string]int)
+	}
+	r.matchGame++
+	r.gameScores = make(map[string]int)
+}
+
+// matchOver reports whether the current game is the last of its match.
+func (r *Room) matchOver() bool {
+	return r.cfg.Scoring && r.matchGame >= r.cfg.MatchGames
+}
+
+// addPoints gives p points, or takes them for a negative number, in scoring mode.
+func (r *Room) addPoints(p *Player, points int) {
+	if !r.cfg.Scoring {
+		return
+	}
+	r.gameScores[p.name] += points
+	r.matchScores[p.name] += points
+}
+
+// scores returns the running scores in seat order, nil without scoring.
+func (r *Room) scores() []game.Score {
+	if !r.cfg.Scoring {
+		return nil
+	}
+	scores := make([]game.Score, 0, len(r.players))
+	for _, p := range r.players {
+		scores = append(scores, game.Score{Name: p.name, Game: r.gameScores[p.name], Match: r.matchScores[p.name]})
+	}
+	return scores
+}
+
+// broadcastScored broadcasts msg with the running scores in scoring mode.
+func (r *Room) broadcastScored(msg game.Message) {
+	if scores := r.scores(); scores != nil {
+		msg.Scores = scores
+		msg.Text += formatScores(scores)
+	}
+	r.broadcastMessage(msg)
+}
+
+func formatScores(scores []game.Score) string {
+	parts := make([]string, 0, len(scores))
+	for _, s := range scores {
+		parts = append(parts, fmt.Sprintf("%s %d (match %d)", s.Name, s.Game, s.Match))
+	}
+	return "Scores: " + strings.Join(parts, " | ") + "\n"
+}
+
+// announceMatch broadcasts the end of a match and who won it with the most points.
+func (r *Room) announceMatch() {
+	scores := r.scores()
+	best := scores[0].Match
+	for _, s := range scores {
+		if s.Match > best {
+			best = s.Match
+		}
+	}
+	var winners []string
+	for _, s := range scores {
+		if s.Match == best {
+			winners = append(winners, s.Name)
+		}
+	}
+
+	text := fmt.Sprintf("Match over! %s won the match with %d points.\n", winners[0], best)
+	if len(winners) > 1 {
+		text = fmt.Sprintf("Match over! %s tied with %d points.\n", strings.Join(winners, " and "), best)
+	}
+	r.broadcastScored(game.Message{Type: game.MATCH, Text: game.GenerateTimestampPrefix() + text})
+	r.logger().Info("match over", "winners", winners, "points", best)
+}
+
+// rejectGuess tells p why its input is not a valid guess, which costs points in scoring mode.
+func (r *Room) rejectGuess(p *Player, input string, err error) {
+	r.lobby.metrics.invalidInputs.inc(r.metricLabels()...)
+	r.recordEvent(ReplayEvent{Type: replayInvalid, Player: p.name, Input: input})
+	r.addPoints(p, penaltyInvalid)
+	msg := game.Message{Type: game.INFO, Text: "Invalid input: " + err.Error() + "\n"}
+	if scores := r.scores(); scores != nil {
+		msg.Scores = scores
+		msg.Text += fmt.Sprintf("%d points. %s", penaltyInvalid, formatScores(scores))
+	}
+	p.sendMessage(msg)
+}
@@ -0,0 +1,104 @@
+package netpkg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"code_breaker/internal/game"
+)
+
+func TestGuessPoints_RewardNewInformation(t *testing.T) {
+	assert.Equal(t, 15, guessPoints(nil, game.GuessRecord{CorrectPlace: 1}))
+	assert.Equal(t, 10, guessPoints(nil, game.GuessRecord{WrongPlace: 2}))
+
+	history := []game.GuessRecord{{CorrectPlace: 1, WrongPlace: 2}}
+	assert.Zero(t, guessPoints(history, game.GuessRecord{CorrectPlace: 1, WrongPlace: 1}))
+	assert.Equal(t, 10, guessPoints(history, game.GuessRecord{CorrectPlace: 2, WrongPlace: 1}))
+	assert.Equal(t, 25, guessPoints(history, game.GuessRecord{CorrectPlace: 3, WrongPlace: 1}))
+}
+
+func TestParseRoomOptions_Scoring(t *testing.T) {
+	_, cfg, err := parseRoomOptions(testDefaults, []string{"scoring=true", "games=5"})
+	require.NoError(t, err)
+	assert.True(t, cfg.Scoring)
+	assert.Equal(t, 5, cfg.MatchGames)
+
+	_, _, err = parseRoomOptions(testDefaults, []string{"scoring=maybe"})
+	assert.Error(t, err)
+	cfg.MatchGames = 0
+	assert.Error(t, cfg.Validate())
+}
+
+func TestRoom_ScoringMatch(t *testing.T) {
+	cfg := testConfig(1)
+	cfg.Scoring = true
+	cfg.MatchGames = 2
+	lobby := newTestLobby(t, cfg)
+	room := lobby.room(DefaultRoom)
+	addr := serveTestLobby(t, lobby)
+
+	alice := dialPlayer(t, addr, "alice", joinMain)
+	newGame := alice.expect(game.NEWGAME)
+	assert.Contains(t, newGame.Text, "Game 1 of 2 of the match")
+	alice.expect(game.TURN)
+	alice.send("12")
+	invalid := alice.expect(game.INFO)
+	assert.Equal(t, []game.Score{{Name: "alice", Game: -5, Match: -5}}, invalid.Scores)
+
+	// solving at once finds every digit and place: 4*10 + 4*5, and the bonus
+	alice.expect(game.TURN)
+	guessSecret(t, room, alice)
+	win := alice.expect(game.WIN)
+	assert.Equal(t, []game.Score{{Name: "alice", Game: 105, Match: 105}}, win.Scores)
+	assert.Contains(t, win.Text, "Scores: alice 105 (match 105)\n")
+
+	assert.Equal(t, "New game starting in 3 seconds...\n", alice.expect(game.NEWGAME).Text)
+	assert.Contains(t, alice.expect(game.NEWGAME).Text, "Game 2 of 2 of the match")
+	// a game solved in steps earns as much for the information it found
+	alice.expect(game.TURN)
+	alice.send("0000")
+	alice.expect(game.RESULT)
+	alice.expect(game.TURN)
+	guessSecret(t, room, alice)
+	alice.expect(game.WIN)
+	match := alice.expect(game.MATCH)
+	require.Len(t, match.Scores, 1)
+	assert.Equal(t, 105+110, match.Scores[0].Match)
+	assert.Equal(t, 110, match.Scores[0].Game)
+	assert.Contains(t, match.Text, "alice won the match with 215 points.")
+
+	// the next game starts a new match
+	assert.Equal(t, "New match starting in 3 seconds...\n", alice.expect(game.NEWGAME).Text)
+	assert.Contains(t, alice.expect(game.NEWGAME).Text, "Game 1 of 2 of the match")
+	records := lobby.games.Records(DefaultRoom)
+	require.Len(t, records, 2)
+	assert.Equal(t, map[string]int{"alice": 105}, records[0].Scores)
+	assert.Equal(t, 2, records[1].MatchGame)
+}
+
+func TestRoom_ScoringPenalizesTimeouts(t *testing.T) {
+	cfg := testConfig(2)
+	cfg.Scoring = true
+	cfg.MatchGames = 1
+	cfg.TurnTimeSeconds = 1
+	lobby := newTestLobby(t, cfg)
+	addr := serveTestLobby(t, lobby)
+
+	alice := dialPlayer(t, addr, "alice", joinMain)
+	bob := dialPlayer(t, addr, "bob", joinMain)
+	name := "alice"
+	if !alice.expectTurnOrWait() {
+		name = "bob"
+	}
+	timeout := bob.expect(game.TIMEOUT)
+	for _, s := range timeout.Scores {
+		if s.Name == name {
+			assert.Equal(t, game.Score{Name: name, Game: -10, Match: -10}, s)
+		} else {
+			assert.Zero(t, s.Game)
+		}
+	}
+	assert.Len(t, timeout.Scores, 2)
+}
@@ -1,20 +1,21 @@
 package netpkg
 
 import (
-	"encoding/json"
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"log"
-	"math/rand"
 	"net"
-	"sort"
-	"strings"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"code_breaker/internal/game"
 )
 
-var cfg game.Config
-
 const (
 	ColorReset  = "\033[0m"
 	ColorRed    = "\033[31m"
@@ -25,275 +26,172 @@ const (
 	ColorPurple = "\033[35m"
 )
 
-type Player struct {
-	conn net.Conn
-	id   int
-}
+// DefaultRoom is the room created at startup from the environment settings.
+const DefaultRoom = "main"
 
-type Analytics struct {
-	GamesPlayed     int
-	WinsByPlayer    map[int]int
-	LossesByPlayer  map[int]int
-	GuessesUntilWin map[int]int
-}
+// WebSocketPath is where the HTTP server accepts WebSocket clients.
+const WebSocketPath = "/ws"
 
-func StartServer() {
-	cfg = game.LoadConfig()
-	listener, err := net.Listen("tcp", "0.0.0.0:8080")
+// StartServer runs the server with the room settings from the environment until
+// it receives SIGINT or SIGTERM and shuts down, or a listener fails.
+func StartServer(serverCfg game.ServerConfig) {
+	if err := configureLogging(os.Stderr, serverCfg); err != nil {
+		log.Fatalf("Error configuring logging: %v", err)
+	}
+	cfg := game.LoadConfig()
+	tlsCfg, err := serverTLSConfig(serverCfg)
 	if err != nil {
-		log.Fatalf("Error starting server: %v", err)
+		serverLog.Fatal("error loading TLS certificate", "err", err)
 	}
-	defer listener.Close()
-
-	fmt.Printf("Server started. \nSettings: codeLength=%d | difficulty=%s | TurnTimeSeconds=%d \nWaiting for %d players...\n",
-		cfg.CodeLength, cfg.Difficulty, cfg.TurnTimeSeconds, cfg.MaxPlayers)
-
-	gameRng := rand.New(rand.NewSource(time.Now().UnixNano()))
-	var players []*Player
-
-	analytics := &Analytics{
-		WinsByPlayer:    make(map[int]int),
-		LossesByPlayer:  make(map[int]int),
-		GuessesUntilWin: make(map[int]int),
+	if len(serverCfg.ListenAddrs) == 0 {
+		serverLog.Fatal("error starting server: no listen address")
 	}
-
-	// Accept players
-	for len(players) < cfg.MaxPlayers {
-		conn, err := listener.Accept()
+	listeners := make([]net.Listener, 0, len(serverCfg.ListenAddrs))
+	for _, addr := range serverCfg.ListenAddrs {
+		listener, err := listen(addr, tlsCfg)
 		if err != nil {
-			log.Fatalf("Error accepting connection: %v", err)
-		}
-
-		player := &Player{
-			id:   len(players) + 1,
-			conn: conn,
+			serverLog.Fatal("error starting server", "addr", addr, "err", err)
 		}
-		players = append(players, player)
-
-		log.Printf("Player %d connected\n", player.id)
-		writeToClient(conn, game.INFO, fmt.Sprintf("Welcome Player %d! Waiting for others...\n", player.id))
+		defer listener.Close()
+		listeners = append(listeners, listener)
 	}
 
-	broadcast(players, game.INFO, "All players connected. Game starting now!\n")
-
-	currentTurn := gameRng.Intn(len(players))
-	consecutiveTimeouts := 0
-
-	for {
-		game.SetCodeDigits(cfg.CodeLength)
-		secret := game.GenerateSecretCodeWithDifficulty(cfg.CodeLength, cfg.Difficulty)
-		log.Printf("DEBUG NEW SECRET: %d\n", secret)
-		currentGameGuesses := 0
-
-		broadcast(players, game.NEWGAME, "New game started!\n")
-
-		for {
-			currentPlayer := players[currentTurn]
-			notifyTurns(players, currentPlayer)
-
-			if cfg.MaxPlayers > 1 {
-				_ = currentPlayer.conn.SetReadDeadline(time.Now().Add(time.Second * time.Duration(cfg.TurnTimeSeconds)))
-			}
-			buffer := make([]byte, 1024)
-			n, err := currentPlayer.conn.Read(buffer)
-
-			// Timeout handling
-			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-				broadcast(players, game.TIMEOUT, fmt.Sprintf("Player %d ran out of time and forfeited the turn!\n", currentPlayer.id))
-				consecutiveTimeouts++
-				if consecutiveTimeouts >= len(players) {
-					handleRecovery(players, secret, &currentTurn, &consecutiveTimeouts, analytics, gameRng, &currentGameGuesses)
-					continue
-				}
-				currentTurn = (currentTurn + 1) % len(players)
-				drainLateInput(currentPlayer.conn)
-				continue
-			}
-
-			if err != nil {
-				log.Printf("Player %d disconnected: %v\n", currentPlayer.id, err)
-				return
-			}
-
-			_ = currentPlayer.conn.SetReadDeadline(time.Time{})
-
-			guess := strings.TrimSpace(string(buffer[:n]))
-			numGuess, err := game.ValidateGuess(guess)
-			if err != nil {
-				writeToClient(currentPlayer.conn, game.INFO, "Invalid input: "+err.Error()+"\n")
-				continue
-			}
-
-			// Valid guess
-			consecutiveTimeouts = 0
-			currentGameGuesses++
-
-			feedback := game.GenerateFeedback(secret, numGuess, gameRng)
-			if feedback.CorrectPlace == cfg.CodeLength {
-				handleWin(players, currentPlayer, secret, analytics, currentGameGuesses)
-				time.Sleep(3 * time.Second)
-				currentTurn = (currentTurn + 1) % len(players)
-				break
-			}
-
-			msg := fmt.Sprintf(
-				ColorBlue+"player: %d\n"+ColorCyan+"Number guessed: %d\n"+ColorGreen+"Correctly placed: %d\n"+ColorYellow+"Wrongly placed: %d\n"+ColorPurple+"Hint: %s\n"+ColorReset,
-				currentPlayer.id, numGuess, feedback.CorrectPlace, feedback.WrongPlace, feedback.Hint,
-			)
-			broadcast(players, game.RESULT, game.GenerateTimestampPrefix()+msg)
-			currentTurn = (currentTurn + 1) % len(players)
+	var accounts *AccountStore
+	if serverCfg.AccountsFile != "" {
+		if accounts, err = OpenAccountStore(serverCfg.AccountsFile); err != nil {
+			serverLog.Fatal("error opening accounts", "file", serverCfg.AccountsFile, "err", err)
 		}
 	}
-}
-
-func handleRecovery(players []*Player, secret int, currentTurn *int, consecutiveTimeouts *int, analytics *Analytics, gameRng *rand.Rand, currentGameGuesses *int) {
-	broadcast(players, game.RECOVERY, "All players timed out. Waiting for ANY player to resume...\n")
-
-	var resumePlayer *Player
-	var guess string
-	found := false
 
-	for !found {
-		for _, p := range players {
-			_ = p.conn.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
-			buf := make([]byte, 1024)
-			n, err := p.conn.Read(buf)
-			if err != nil {
-				if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-					continue
-				}
-				log.Printf("Error during recovery read from player %d: %v", p.id, err)
-				continue
-			}
-			_ = p.conn.SetReadDeadline(time.Time{})
-			resumePlayer = p
-			guess = strings.TrimSpace(string(buf[:n]))
-			found = true
-			break
+	var games *GameStore
+	if serverCfg.AnalyticsFile != "" {
+		if games, err = OpenGameStore(serverCfg.AnalyticsFile); err != nil {
+			serverLog.Fatal("error opening the game records", "file", serverCfg.AnalyticsFile, "err", err)
 		}
+		defer games.Close()
 	}
 
-	numGuess, err := game.ValidateGuess(guess)
-	if err != nil {
-		writeToClient(resumePlayer.conn, game.INFO, "Invalid input: "+err.Error()+"\n")
-		*consecutiveTimeouts = 0
-		*currentTurn = resumePlayer.id - 1
-		return
-	}
-
-	*currentGameGuesses++
-	feedback := game.GenerateFeedback(secret, numGuess, gameRng)
-	if feedback.CorrectPlace == cfg.CodeLength {
-		handleWin(players, resumePlayer, secret, analytics, *currentGameGuesses)
-		time.Sleep(3 * time.Second)
-		*currentTurn = resumePlayer.id % len(players)
-		*consecutiveTimeouts = 0
-		return
+	lobby := NewLobby(cfg, game.LoadMatchmakingConfig(), accounts, games)
+	if serverCfg.ReplayDir != "" {
+		if err := lobby.EnableReplays(serverCfg.ReplayDir); err != nil {
+			serverLog.Fatal("error creating the replay directory", "dir", serverCfg.ReplayDir, "err", err)
+		}
 	}
-
-	msg := fmt.Sprintf(
-		ColorBlue+"player: %d\n"+ColorCyan+"Number guessed: %d\n"+ColorGreen+"Correctly placed: %d\n"+ColorYellow+"Wrongly placed: %d\n"+ColorPurple+"Hint: %s\n"+ColorReset,
-		resumePlayer.id, numGuess, feedback.CorrectPlace, feedback.WrongPlace, feedback.Hint,
-	)
-	broadcast(players, game.RESULT, game.GenerateTimestampPrefix()+msg)
-	*consecutiveTimeouts = 0
-	*currentTurn = resumePlayer.id % len(players)
-}
-
-func handleWin(players []*Player, winner *Player, secret int, analytics *Analytics, currentGameGuesses int) {
-	winMsg := fmt.Sprintf("Player %d won! Secret was %d\n", winner.id, secret)
-
-	analytics.GamesPlayed++
-	analytics.WinsByPlayer[winner.id]++
-	analytics.GuessesUntilWin[secret] = currentGameGuesses
-
-	for _, p := range players {
-		if p.id != winner.id {
-			analytics.LossesByPlayer[p.id]++
+	if _, err := lobby.CreateRoom(DefaultRoom, cfg); err != nil {
+		serverLog.Fatal("error creating room", "room", DefaultRoom, "err", err)
+	}
+
+	serverLog.Info("server started", "room", DefaultRoom, "code_length", cfg.CodeLength, "difficulty", cfg.Difficulty,
+		"turn_time_seconds", cfg.TurnTimeSeconds, "max_players", cfg.MaxPlayers)
+
+	health := newHealth(lobby)
+	for _, listener := range listeners {
+		health.addListener(listener)
+	}
+	var httpServer *http.Server
+	if serverCfg.HTTPPort != 0 {
+		httpServer = startHTTPServer(lobby, health, serverCfg, tlsCfg)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	errs := make(chan error, len(listeners))
+	for _, listener := range listeners {
+		serverLog.Info("listening", "network", listener.Addr().Network(), "addr", listener.Addr())
+		go func(listener net.Listener) {
+			err := serve(listener, lobby)
+			health.listenerDown(listener.Addr(), err)
+			errs <- err
+		}(listener)
+	}
+	var sig os.Signal
+	select {
+	case err := <-errs:
+		serverLog.Fatal("error accepting connection", "err", err)
+	case sig = <-signals:
+	}
+
+	timeout := time.Duration(serverCfg.ShutdownTimeoutSeconds) * time.Second
+	serverLog.Info("shutting down, send the signal again to stop at once", "signal", sig, "timeout", timeout)
+	go func() {
+		<-signals
+		serverLog.Fatal("stopped without shutting down")
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	for _, listener := range listeners {
+		_ = listener.Close()
+	}
+	lobby.Shutdown(ctx, serverCfg.ShutdownFinishTurn)
+	if httpServer != nil {
+		// the HTTP server stays up while draining, so /readyz can tell
+		if err := httpServer.Shutdown(ctx); err != nil {
+			_ = httpServer.Close()
 		}
 	}
-
-	broadcast(players, game.WIN, game.GenerateTimestampPrefix()+winMsg)
-	broadcast(players, game.NEWGAME, "New game starting in 3 seconds...\n")
-	printAnalytics(analytics)
+	serverLog.Info("server stopped")
 }
 
-func notifyTurns(players []*Player, currentPlayer *Player) {
-	for _, p := range players {
-		if p.id == currentPlayer.id {
-			writeToClient(p.conn, game.TURN, "Your turn!\n")
+// startHTTPServer serves the web client, WebSocket clients and the API on the HTTP port.
+func startHTTPServer(lobby *Lobby, health *Health, serverCfg game.ServerConfig, tlsCfg *tls.Config) *http.Server {
+	httpServer := &http.Server{
+		Addr:    fmt.Sprintf(":%d", serverCfg.HTTPPort),
+		Handler: newHTTPHandler(lobby, health, serverCfg),
+	}
+	scheme := "http"
+	if tlsCfg != nil {
+		// WebSocket upgrades need HTTP/1.1
+		httpServer.TLSConfig = tlsCfg.Clone()
+		httpServer.TLSConfig.NextProtos = []string{"http/1.1"}
+		scheme = "https"
+	}
+	go func() {
+		var err error
+		if tlsCfg != nil {
+			err = httpServer.ListenAndServeTLS("", "")
 		} else {
-			writeToClient(p.conn, game.WAIT, fmt.Sprintf("Waiting for Player %d...\n", currentPlayer.id))
+			err = httpServer.ListenAndServe()
 		}
-	}
+		if !errors.Is(err, http.ErrServerClosed) {
+			serverLog.Fatal("error starting HTTP server", "err", err)
+		}
+	}()
+	serverLog.Info("play in the browser", "url", fmt.Sprintf("%s://localhost:%d/", scheme, serverCfg.HTTPPort), "websocket_path", WebSocketPath)
+	return httpServer
 }
 
-func drainLateInput(conn net.Conn) {
-	_ = conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
-	buf := make([]byte, 1024)
+// serve accepts connections into the lobby until the listener fails.
+func serve(listener net.Listener, lobby *Lobby) error {
 	for {
-		_, err := conn.Read(buf)
+		conn, err := listener.Accept()
 		if err != nil {
-			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-				break
-			}
-			break
+			return err
 		}
+		lobby.metrics.connections.inc(listener.Addr().Network())
+		go lobby.Serve(newClientConn(conn, writeTimeout))
 	}
-	_ = conn.SetReadDeadline(time.Time{})
 }
 
-func broadcast(players []*Player, msgType game.MessageType, msg string) {
-	for _, p := range players {
-		writeToClient(p.conn, msgType, msg)
-	}
-}
-
-func writeToClient(conn net.Conn, msgType game.MessageType, text string) {
-	msg := game.Message{Type: msgType, Text: text}
-	data, err := json.Marshal(msg)
-	if err != nil {
-		log.Printf("Error marshaling message: %v", err)
-		return
-	}
-	_, err = conn.Write(append(data, '\n'))
-	if err != nil {
-		log.Printf("Error writing to client: %v", err)
-	}
+// newHTTPHandler routes the requests of the HTTP server.
+func newHTTPHandler(lobby *Lobby, health *Health, cfg game.ServerConfig) http.Handler {
+	api := &apiHandler{lobby: lobby, adminToken: cfg.AdminToken}
+	mux := http.NewServeMux()
+	mux.Handle(WebSocketPath, websocketHandler(lobby))
+	mux.HandleFunc(healthzPath, health.serveHealthz)
+	mux.HandleFunc(readyzPath, health.serveReadyz)
+	mux.Handle(metricsPath, metricsHandler(lobby))
+	mux.Handle(apiRoomsPath, api)
+	mux.Handle(apiRoomsPath+"/", api)
+	mux.Handle(apiStatsPath, statsHandler(lobby))
+	mux.HandleFunc(apiExportPath, api.serveExport)
+	mux.Handle(apiLeaderboardPath, leaderboardHandler(lobby))
+	mux.Handle("/", webClientHandler())
+	return mux
 }
 
-func printAnalytics(a *Analytics) {
-	log.Println("====== GAME ANALYTICS ======")
-	log.Printf("Games Played: %d\n", a.GamesPlayed)
-	for pid, wins := range a.WinsByPlayer {
-		log.Printf("Player %d Wins: %d\n", pid, wins)
-	}
-	for pid, losses := range a.LossesByPlayer {
-		log.Printf("Player %d Losses: %d\n", pid, losses)
-	}
-
-	type hardEntry struct {
-		secret  int
-		guesses int
-	}
-	hardList := make([]hardEntry, 0)
-	for secret, guesses := range a.GuessesUntilWin {
-		hardList = append(hardList, hardEntry{secret: secret, guesses: guesses})
-	}
-
-	sort.Slice(hardList, func(i, j int) bool { return hardList[i].guesses > hardList[j].guesses })
-	limit := 5
-	if len(hardList) < limit {
-		limit = len(hardList)
-	}
-
-	if limit > 0 {
-		log.Println("Top hardest secrets (by guesses until win):")
-		for i := 0; i < limit; i++ {
-			log.Printf("#%d Secret: %d | Guesses until win: %d\n", i+1, hardList[i].secret, hardList[i].guesses)
-		}
-	} else {
-		log.Println("No completed games yet to determine hardest secrets.")
-	}
-	log.Println("============================")
-}
+// writeTimeout bounds how long a message may take to reach a client, and sendQueueSize how many
+// messages may wait for it. A client that stops reading is dropped when either runs out.
+const (
+	writeTimeout  = 10 * time.Second
+	sendQueueSize = 256
+)
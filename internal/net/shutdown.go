@@ -0,0 +1,117 @@
+package netpkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"code_breaker/internal/game"
+)
+
+const shutdownNotice = "The server is shutting down. Goodbye!\n"
+
+var errShuttingDown = errors.New("the server is shutting down")
+
+// Shutdown stops the lobby from taking connections and rooms, tells everyone the server is
+// stopping and closes all rooms, at the latest when ctx is done. With finishTurn set, rooms
+// in the middle of a game close once their current turn ends.
+func (l *Lobby) Shutdown(ctx context.Context, finishTurn bool) {
+	l.mu.Lock()
+	l.shuttingDown = true
+	rooms := make([]*Room, 0, len(l.order))
+	for _, name := range l.order {
+		rooms = append(rooms, l.rooms[name])
+	}
+	conns := make([]*clientConn, 0, len(l.conns))
+	for conn := range l.conns {
+		conns = append(conns, conn)
+	}
+	l.mu.Unlock()
+
+	for _, conn := range conns {
+		conn.send(game.SHUTDOWN, shutdownNotice)
+		_ = conn.Close()
+	}
+
+	left := secondsLeft(ctx)
+	for _, room := range rooms {
+		_ = room.shutdown(finishTurn, left)
+	}
+
+	countdownCtx, stopCountdown := context.WithCancel(ctx)
+	defer stopCountdown()
+	go countdown(countdownCtx, rooms)
+
+	for _, room := range rooms {
+		select {
+		case <-room.done:
+		case <-ctx.Done():
+			_ = room.do(func() error {
+				room.closeForShutdown()
+				return nil
+			})
+		}
+	}
+}
+
+// countdown reminds the rooms still open how long they have left until ctx is done.
+func countdown(ctx context.Context, rooms []*Room) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		left := secondsLeft(ctx)
+		if left <= 0 || (left > 5 && left%10 != 0) {
+			continue
+		}
+		msg := game.Message{
+			Type:            game.SHUTDOWN,
+			Text:            fmt.Sprintf("The server shuts down in %d seconds.\n", left),
+			TimeLeftSeconds: left,
+		}
+		for _, room := range rooms {
+			_ = room.do(func() error {
+				room.broadcastMessage(msg)
+				return nil
+			})
+		}
+	}
+}
+
+// secondsLeft is the time until ctx's deadline, rounded to seconds; 0 without one.
+func secondsLeft(ctx context.Context) int {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0
+	}
+	return int(time.Until(deadline).Round(time.Second).Seconds())
+}
+
+// shutdown closes the room for a server shutdown, or with finishTurn lets the turn in
+// progress end first. Rooms without a game, or without anyone to play it, close at once.
+func (r *Room) shutdown(finishTurn bool, secondsLeft int) error {
+	return r.do(func() error {
+		if !finishTurn || !r.started || r.recovering || !r.humanConnected() {
+			r.closeForShutdown()
+			return nil
+		}
+		r.draining = true
+		r.broadcastMessage(game.Message{
+			Type:            game.SHUTDOWN,
+			Text:            fmt.Sprintf("The server shuts down in %d seconds. The current turn is the last one.\n", secondsLeft),
+			TimeLeftSeconds: secondsLeft,
+		})
+		return nil
+	})
+}
+
+// closeForShutdown flushes the room's analytics and closes it.
+func (r *Room) closeForShutdown() {
+	logAnalytics(r.logger(), r.analytics)
+	r.close(game.SHUTDOWN, shutdownNotice)
+}
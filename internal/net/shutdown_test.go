@@ -0,0 +1,97 @@
+package netpkg
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"code_breaker/internal/game"
+)
+
+// shutdownAsync runs lobby.Shutdown and closes the returned channel when it is done.
+func shutdownAsync(lobby *Lobby, timeout time.Duration, finishTurn bool) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		lobby.Shutdown(ctx, finishTurn)
+		close(done)
+	}()
+	return done
+}
+
+func TestShutdown_FinishesCurrentTurn(t *testing.T) {
+	lobby := newTestLobby(t, testConfig(1))
+	_, err := lobby.CreateRoom("waiting", testConfig(2))
+	require.NoError(t, err)
+	addr := serveTestLobby(t, lobby)
+
+	player := dialPlayer(t, addr, "alice", joinMain)
+	player.expect(game.TURN)
+	waiting := dialPlayer(t, addr, "bob", game.CmdJoin+" waiting")
+	waiting.expect(game.SESSION)
+	idle := dialPlayer(t, addr, "carol")
+
+	done := shutdownAsync(lobby, 10*time.Second, true)
+
+	// the lobby and the room that has not started close at once
+	assert.Equal(t, shutdownNotice, idle.expect(game.SHUTDOWN).Text)
+	idle.expectClosed()
+	assert.Equal(t, shutdownNotice, waiting.expect(game.SHUTDOWN).Text)
+	waiting.expectClosed()
+
+	notice := player.expect(game.SHUTDOWN)
+	assert.Contains(t, notice.Text, "The current turn is the last one")
+	assert.InDelta(t, 10, notice.TimeLeftSeconds, 1)
+	player.send("0000")
+	player.expect(game.RESULT)
+	assert.Equal(t, shutdownNotice, player.expect(game.SHUTDOWN).Text)
+	player.expectClosed()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdown did not finish after the last turn")
+	}
+	assert.Empty(t, lobby.Rooms())
+
+	late := dialTestClient(t, addr, game.CmdRooms)
+	late.expect(game.SHUTDOWN)
+	late.expectClosed()
+	_, err = lobby.CreateRoom("late", testConfig(1))
+	assert.ErrorIs(t, err, errShuttingDown)
+}
+
+func TestShutdown_DeadlineClosesRooms(t *testing.T) {
+	lobby := newTestLobby(t, testConfig(1))
+	addr := serveTestLobby(t, lobby)
+	player := dialPlayer(t, addr, "alice", joinMain)
+	player.expect(game.TURN)
+
+	done := shutdownAsync(lobby, time.Second, true)
+	player.expect(game.SHUTDOWN)
+	// the turn is never played
+	assert.Equal(t, shutdownNotice, player.expect(game.SHUTDOWN).Text)
+	player.expectClosed()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdown did not respect its deadline")
+	}
+	assert.Empty(t, lobby.Rooms())
+}
+
+func TestShutdown_WithoutFinishingTheTurn(t *testing.T) {
+	lobby := newTestLobby(t, testConfig(1))
+	addr := serveTestLobby(t, lobby)
+	player := dialPlayer(t, addr, "alice", joinMain)
+	player.expect(game.TURN)
+
+	<-shutdownAsync(lobby, 10*time.Second, false)
+	assert.Equal(t, shutdownNotice, player.expect(game.SHUTDOWN).Text)
+	player.expectClosed()
+}
@@ -0,0 +1,212 @@
+package netpkg
+
+import (
+	"math"
+	"net/http"
+	"sort"
+
+	"code_breaker/internal/game"
+)
+
+// apiStatsPath serves the stats of the recorded games:
+//
+//	GET /api/stats?room=&difficulty=&codeLength=&since=&until=
+//
+// All parameters are optional, see parseRecordFilter.
+const apiStatsPath = "/api/stats"
+
+// StatsReport summarizes recorded games, overall and for every combination of difficulty and code length.
+type StatsReport struct {
+	Overall  Stats         `json:"overall"`
+	ByConfig []ConfigStats `json:"byConfig"`
+}
+
+// ConfigStats are the stats of the games played with one difficulty and code length.
+type ConfigStats struct {
+	Difficulty game.Difficulty `json:"difficulty"`
+	CodeLength int             `json:"codeLength"`
+	Stats
+}
+
+// Stats summarizes a set of games. Averages and medians over no games are 0.
+type Stats struct {
+	Games                int           `json:"games"` // recorded games, won or abandoned
+	GamesWon             int           `json:"gamesWon"`
+	AvgGuessesToWin      float64       `json:"avgGuessesToWin"`
+	MedianGuessesToWin   float64       `json:"medianGuessesToWin"`
+	AvgSecondsToSolve    float64       `json:"avgSecondsToSolve"`
+	MedianSecondsToSolve float64       `json:"medianSecondsToSolve"`
+	Turns                int           `json:"turns"` // turns of human players
+	Timeouts             int           `json:"timeouts"`
+	TimeoutRate          float64       `json:"timeoutRate"` // share of the turns that timed out
+	Recoveries           int           `json:"recoveries"`
+	RecoveriesPerGame    float64       `json:"recoveriesPerGame"`
+	Players              []PlayerStats `json:"players"`
+}
+
+// PlayerStats describes how a player did. Accuracy is the share of digits a player's guesses had in the right place.
+type PlayerStats struct {
+	Name     string  `json:"name"`
+	Bot      bool    `json:"bot,omitempty"`
+	Games    int     `json:"games"`
+	Wins     int     `json:"wins"`
+	Guesses  int     `json:"guesses"`
+	Accuracy float64 `json:"accuracy"`
+}
+
+// computeStats reports on the records that match filter.
+func computeStats(records []GameRecord, filter RecordFilter) StatsReport {
+	type configKey struct {
+		difficulty game.Difficulty
+		codeLength int
+	}
+	var all []GameRecord
+	byConfig := make(map[configKey][]GameRecord)
+	for _, rec := range records {
+		if !filter.match(rec) {
+			continue
+		}
+		all = append(all, rec)
+		key := configKey{rec.Config.Difficulty, rec.Config.CodeLength}
+		byConfig[key] = append(byConfig[key], rec)
+	}
+
+	report := StatsReport{Overall: stats(all), ByConfig: make([]ConfigStats, 0, len(byConfig))}
+	for key, recs := range byConfig {
+		report.ByConfig = append(report.ByConfig, ConfigStats{Difficulty: key.difficulty, CodeLength: key.codeLength, Stats: stats(recs)})
+	}
+	sort.Slice(report.ByConfig, func(i, j int) bool {
+		a, b := report.ByConfig[i], report.ByConfig[j]
+		if a.Difficulty != b.Difficulty {
+			return difficultyRank(a.Difficulty) < difficultyRank(b.Difficulty)
+		}
+		return a.CodeLength < b.CodeLength
+	})
+	return report
+}
+
+func difficultyRank(d game.Difficulty) int {
+	for i, known := range []game.Difficulty{game.DifficultyEasy, game.DifficultyMedium, game.DifficultyHard} {
+		if d == known {
+			return i
+		}
+	}
+	return 3
+}
+
+func stats(records []GameRecord) Stats {
+	s := Stats{Games: len(records), Players: make([]PlayerStats, 0)}
+	var guessesToWin, secondsToSolve []float64
+	players := make(map[string]*PlayerStats)
+	correct := make(map[string]int)
+	digits := make(map[string]int)
+
+	for _, rec := range records {
+		s.Turns += rec.Turns
+		s.Timeouts += rec.Timeouts
+		s.Recoveries += rec.Recoveries
+		if rec.Winner != "" {
+			s.GamesWon++
+			guessesToWin = append(guessesToWin, float64(len(rec.Guesses)))
+			secondsToSolve = append(secondsToSolve, rec.DurationSeconds)
+		}
+		for _, p := range rec.Players {
+			ps := players[p.Name]
+			if ps == nil {
+				ps = &PlayerStats{Name: p.Name, Bot: p.Bot}
+				players[p.Name] = ps
+			}
+			ps.Games++
+			if p.Name == rec.Winner {
+				ps.Wins++
+			}
+		}
+		for _, g := range rec.Guesses {
+			if ps := players[g.PlayerName]; ps != nil {
+				ps.Guesses++
+				correct[g.PlayerName] += g.CorrectPlace
+				digits[g.PlayerName] += rec.Config.CodeLength
+			}
+		}
+	}
+
+	s.AvgGuessesToWin, s.MedianGuessesToWin = mean(guessesToWin), median(guessesToWin)
+	s.AvgSecondsToSolve, s.MedianSecondsToSolve = mean(secondsToSolve), median(secondsToSolve)
+	s.TimeoutRate = ratio(s.Timeouts, s.Turns)
+	s.RecoveriesPerGame = ratio(s.Recoveries, s.Games)
+	for name, ps := range players {
+		ps.Accuracy = ratio(correct[name], digits[name])
+		s.Players = append(s.Players, *ps)
+	}
+	sort.Slice(s.Players, func(i, j int) bool { return s.Players[i].Name < s.Players[j].Name })
+	return s
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+func ratio(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
+
+// logStats logs the stats of a report, one line overall and one per difficulty and code length.
+func logStats(l *Logger, report StatsReport) {
+	logStatsLine(l, "stats", report.Overall)
+	for _, cs := range report.ByConfig {
+		logStatsLine(l.With("difficulty", cs.Difficulty, "code_length", cs.CodeLength), "stats by settings", cs.Stats)
+	}
+}
+
+func logStatsLine(l *Logger, msg string, s Stats) {
+	accuracy := make(map[string]float64, len(s.Players))
+	for _, p := range s.Players {
+		accuracy[p.Name] = round2(p.Accuracy)
+	}
+	l.Info(msg, "games", s.Games, "games_won", s.GamesWon,
+		"avg_guesses_to_win", round2(s.AvgGuessesToWin), "median_guesses_to_win", s.MedianGuessesToWin,
+		"avg_seconds_to_solve", round2(s.AvgSecondsToSolve), "median_seconds_to_solve", round2(s.MedianSecondsToSolve),
+		"timeout_rate", round2(s.TimeoutRate), "recoveries_per_game", round2(s.RecoveriesPerGame), "accuracy", accuracy)
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+func statsHandler(lobby *Lobby) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		filter, err := parseRecordFilter(r.URL.Query())
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, computeStats(lobby.games.Records(filter.Room), filter))
+	})
+}
@@ -0,0 +1,105 @@
+package netpkg
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"code_breaker/internal/game"
+)
+
+func statsTestRecords() []GameRecord {
+	medium4 := RecordedConfig{CodeLength: 4, Difficulty: game.DifficultyMedium}
+	hard5 := RecordedConfig{CodeLength: 5, Difficulty: game.DifficultyHard}
+	players := []RecordedPlayer{{Name: "alice"}, {Name: "bot", Bot: true}}
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	return []GameRecord{
+		{Room: "a", Config: medium4, Players: players, Winner: "alice", Turns: 4, Timeouts: 1, Ended: start, DurationSeconds: 30,
+			Guesses: []game.GuessRecord{{PlayerName: "alice", CorrectPlace: 1}, {PlayerName: "bot", CorrectPlace: 2}, {PlayerName: "alice", CorrectPlace: 4}}},
+		{Room: "a", Config: medium4, Players: players, Winner: "bot", Turns: 2, Ended: start.Add(time.Hour), DurationSeconds: 90,
+			Guesses: []game.GuessRecord{{PlayerName: "alice", CorrectPlace: 0}, {PlayerName: "bot", CorrectPlace: 4}}},
+		{Room: "b", Config: hard5, Players: players[:1], Turns: 4, Timeouts: 4, Recoveries: 1, Ended: start.Add(2 * time.Hour), DurationSeconds: 200,
+			Guesses: []game.GuessRecord{{PlayerName: "alice", CorrectPlace: 3}}}, // abandoned
+	}
+}
+
+func TestComputeStats_BreaksDownBySettings(t *testing.T) {
+	report := computeStats(statsTestRecords(), RecordFilter{})
+
+	overall := report.Overall
+	assert.Equal(t, 3, overall.Games)
+	assert.Equal(t, 2, overall.GamesWon)
+	assert.Equal(t, 2.5, overall.AvgGuessesToWin)
+	assert.Equal(t, 2.5, overall.MedianGuessesToWin)
+	assert.Equal(t, 60.0, overall.AvgSecondsToSolve)
+	assert.Equal(t, 0.5, overall.TimeoutRate)
+	assert.InDelta(t, 1.0/3, overall.RecoveriesPerGame, 1e-9)
+	assert.Equal(t, []PlayerStats{
+		{Name: "alice", Games: 3, Wins: 1, Guesses: 4, Accuracy: 8.0 / 17},
+		{Name: "bot", Bot: true, Games: 2, Wins: 1, Guesses: 2, Accuracy: 6.0 / 8},
+	}, overall.Players)
+
+	require.Len(t, report.ByConfig, 2)
+	medium := report.ByConfig[0]
+	assert.Equal(t, game.DifficultyMedium, medium.Difficulty)
+	assert.Equal(t, 4, medium.CodeLength)
+	assert.Equal(t, 2, medium.Games)
+	assert.Equal(t, 1.0/6, medium.TimeoutRate)
+	hard := report.ByConfig[1]
+	assert.Equal(t, game.DifficultyHard, hard.Difficulty)
+	assert.Equal(t, 0, hard.GamesWon)
+	assert.Zero(t, hard.AvgGuessesToWin)
+	assert.Equal(t, 1.0, hard.TimeoutRate)
+	assert.Equal(t, 1.0, hard.RecoveriesPerGame)
+}
+
+func TestMedian(t *testing.T) {
+	assert.Zero(t, median(nil))
+	assert.Equal(t, 3.0, median([]float64{5, 1, 3}))
+	assert.Equal(t, 2.5, median([]float64{4, 1, 3, 2}))
+}
+
+func TestAPI_Stats(t *testing.T) {
+	store := newMemoryGameStore()
+	for _, rec := range statsTestRecords() {
+		rec := rec
+		require.NoError(t, store.Append(&rec))
+	}
+	lobby := NewLobby(testConfig(1), game.MatchmakingConfig{WaitSeconds: 1}, nil, store)
+	handler := newTestHTTPHandler(lobby, game.ServerConfig{})
+
+	get := func(query string) (int, StatsReport) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, apiStatsPath+query, nil))
+		var report StatsReport
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+		}
+		return rec.Code, report
+	}
+
+	code, report := get("")
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 3, report.Overall.Games)
+
+	_, report = get("?room=a")
+	assert.Equal(t, 2, report.Overall.Games)
+	_, report = get("?difficulty=HARD&codeLength=5")
+	assert.Equal(t, 1, report.Overall.Games)
+	require.Len(t, report.ByConfig, 1)
+	_, report = get("?since=2024-01-01T13:00:00Z")
+	assert.Equal(t, 2, report.Overall.Games)
+	_, report = get("?room=nowhere")
+	assert.Zero(t, report.Overall.Games)
+	assert.Empty(t, report.ByConfig)
+
+	for _, query := range []string{"?difficulty=extreme", "?codeLength=0", "?since=yesterday"} {
+		code, _ := get(query)
+		assert.Equal(t, http.StatusBadRequest, code, query)
+	}
+}
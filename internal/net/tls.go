@@ -0,0 +1,116 @@
+package netpkg
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"time"
+
+	"code_breaker/internal/game"
+)
+
+// serverTLSConfig returns the TLS settings of the server listeners, or nil when TLS is off.
+// The certificate is read from files, or generated and self-signed for development.
+func serverTLSConfig(cfg game.ServerConfig) (*tls.Config, error) {
+	var cert tls.Certificate
+	var err error
+	switch {
+	case cfg.TLSCertFile != "" || cfg.TLSKeyFile != "":
+		if cfg.TLSCertFile == "" || cfg.TLSKeyFile == "" {
+			return nil, errors.New("TLS needs both a certificate and a key file")
+		}
+		cert, err = tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+	case cfg.TLSSelfSigned:
+		cert, err = selfSignedCertificate()
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, nil
+}
+
+// selfSignedCertificate generates a certificate for this host that is valid for a year.
+func selfSignedCertificate() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Code Breaker (self-signed)"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	if host, err := os.Hostname(); err == nil {
+		template.DNSNames = append(template.DNSNames, host)
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serverLog.Info("generated a self-signed TLS certificate", "sha256_fingerprint", fmt.Sprintf("%X", sha256.Sum256(der)))
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// clientTLSConfig returns the TLS settings the client dials with, or nil when TLS is off.
+func clientTLSConfig(cfg ClientConfig) (*tls.Config, error) {
+	if !cfg.TLS {
+		return nil, nil
+	}
+	tlsCfg := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify, MinVersion: tls.VersionTLS12}
+	network, address, err := splitNetworkAddress(cfg.Address)
+	if err != nil {
+		return nil, err
+	}
+	if network == "unix" {
+		tlsCfg.ServerName = "localhost"
+	} else if host, _, err := net.SplitHostPort(address); err == nil {
+		tlsCfg.ServerName = host
+	}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA bundle: %w", err)
+		}
+		tlsCfg.RootCAs = x509.NewCertPool()
+		if !tlsCfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.CAFile)
+		}
+	}
+	return tlsCfg, nil
+}
+
+// dial connects to the server at addr, over TLS when tlsCfg is set.
+func dial(addr string, tlsCfg *tls.Config) (net.Conn, error) {
+	network, address, err := splitNetworkAddress(addr)
+	if err != nil {
+		return nil, err
+	}
+	if tlsCfg != nil {
+		return tls.Dial(network, address, tlsCfg)
+	}
+	return net.Dial(network, address)
+}
@@ -0,0 +1,133 @@
+package netpkg
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"code_breaker/internal/game"
+)
+
+// writeTestCertificates creates a CA and a localhost certificate signed by it,
+// and returns the paths of the CA bundle and of the server certificate and key.
+func writeTestCertificates(t *testing.T) (caFile, certFile, keyFile string) {
+	dir := t.TempDir()
+	writePEM := func(name, blockType string, der []byte) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600))
+		return path
+	}
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	ca, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return writePEM("ca.pem", "CERTIFICATE", caDER), writePEM("cert.pem", "CERTIFICATE", der), writePEM("key.pem", "EC PRIVATE KEY", keyDER)
+}
+
+// startTLSTestServer serves a lobby over TLS with the given server settings.
+func startTLSTestServer(t *testing.T, serverCfg game.ServerConfig) string {
+	tlsCfg, err := serverTLSConfig(serverCfg)
+	require.NoError(t, err)
+	require.NotNil(t, tlsCfg)
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", tlsCfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() { _ = serve(listener, newTestLobby(t, testConfig(1))) }()
+	return listener.Addr().String()
+}
+
+// dialTLS connects as the client would and lists the rooms.
+func dialTLS(t *testing.T, cfg ClientConfig) error {
+	tlsCfg, err := clientTLSConfig(cfg)
+	require.NoError(t, err)
+	conn, err := dial(cfg.Address, tlsCfg)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(game.CmdRooms + "\n"))
+	require.NoError(t, err)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg game.Message
+	dec := json.NewDecoder(conn)
+	for msg.Type != game.LOBBY {
+		require.NoError(t, dec.Decode(&msg))
+	}
+	return nil
+}
+
+func TestTLS_ClientVerifiesServerCertificate(t *testing.T) {
+	caFile, certFile, keyFile := writeTestCertificates(t)
+	addr := startTLSTestServer(t, game.ServerConfig{TLSCertFile: certFile, TLSKeyFile: keyFile})
+
+	assert.NoError(t, dialTLS(t, ClientConfig{Address: addr, TLS: true, CAFile: caFile}))
+	assert.NoError(t, dialTLS(t, ClientConfig{Address: addr, TLS: true, InsecureSkipVerify: true}))
+
+	// the test CA is not among the system roots
+	var unknownAuthority x509.UnknownAuthorityError
+	assert.ErrorAs(t, dialTLS(t, ClientConfig{Address: addr, TLS: true}), &unknownAuthority)
+
+	_, err := clientTLSConfig(ClientConfig{Address: addr, TLS: true, CAFile: keyFile})
+	assert.ErrorContains(t, err, "no certificates found")
+}
+
+func TestTLS_SelfSignedCertificate(t *testing.T) {
+	addr := startTLSTestServer(t, game.ServerConfig{TLSSelfSigned: true})
+
+	assert.NoError(t, dialTLS(t, ClientConfig{Address: addr, TLS: true, InsecureSkipVerify: true}))
+	assert.Error(t, dialTLS(t, ClientConfig{Address: addr, TLS: true}))
+}
+
+func TestServerTLSConfig(t *testing.T) {
+	tlsCfg, err := serverTLSConfig(game.ServerConfig{})
+	assert.NoError(t, err)
+	assert.Nil(t, tlsCfg, "TLS is off by default")
+
+	_, certFile, _ := writeTestCertificates(t)
+	_, err = serverTLSConfig(game.ServerConfig{TLSCertFile: certFile})
+	assert.Error(t, err)
+}
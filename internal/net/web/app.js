@@ -0,0 +1,369 @@
+// Browser client for Code Breaker. It speaks the same line protocol as the Go client
+// over the server's WebSocket endpoint: commands and guesses go out as text messages,
+// and every message coming back is a JSON game message.
+"use strict";
+
+const $ = (id) => document.getElementById(id);
+
+const state = {
+  ws: null,
+  name: "",
+  token: sessionStorage.getItem("codebreaker-token") || "",
+  hello: "", // the handshake without a password, to watch again after a reconnect
+  watching: "", // the room watched as a spectator
+  rooms: [],
+  codeLength: 0,
+  resetBoard: false,
+  deadline: 0,
+  retries: 0,
+  commitment: "", // to the secret of the current game
+};
+
+function show(view) {
+  for (const id of ["login-view", "lobby-view", "game-view"]) {
+    $(id).hidden = id !== view;
+  }
+  $("side").hidden = view !== "game-view";
+}
+
+function log(text) {
+  const el = $("log");
+  el.textContent += text.endsWith("\n") ? text : text + "\n";
+  el.scrollTop = el.scrollHeight;
+}
+
+function send(line) {
+  if (state.ws && state.ws.readyState === WebSocket.OPEN) {
+    state.ws.send(line);
+  }
+}
+
+function connect(handshake) {
+  const scheme = location.protocol === "https:" ? "wss:" : "ws:";
+  const ws = new WebSocket(`${scheme}//${location.host}/ws`);
+  state.ws = ws;
+  $("status").textContent = "Connecting...";
+
+  ws.onopen = () => {
+    $("status").textContent = "Connected";
+    state.retries = 0;
+    send(handshake);
+  };
+  ws.onmessage = (event) => handle(JSON.parse(event.data));
+  ws.onclose = () => {
+    if (state.ws !== ws) {
+      return;
+    }
+    setTurn("", false, 0);
+    if (state.token && state.retries < 5) {
+      // the seat is held for us, take it back
+      state.retries++;
+      const delay = 500 * 2 ** state.retries;
+      $("status").textContent = `Disconnected, reconnecting in ${delay / 1000}s...`;
+      setTimeout(() => connect(`/resume ${state.token} color=false`), delay);
+    } else if (state.watching && state.retries < 5) {
+      // spectators have no seat, they watch again
+      state.retries++;
+      const delay = 500 * 2 ** state.retries;
+      $("status").textContent = `Disconnected, reconnecting in ${delay / 1000}s...`;
+      setTimeout(() => connect(`${state.hello}\n/watch ${state.watching}`), delay);
+    } else {
+      $("status").textContent = "Disconnected";
+      show("login-view");
+    }
+  };
+}
+
+function handle(msg) {
+  if (msg.scores) {
+    // scoring mode: every scored event carries the running scores
+    renderScores(msg.scores);
+  }
+  switch (msg.type) {
+    case "LOBBY":
+      // the server only lists rooms to connections in the lobby, so there is no seat to return to
+      state.token = "";
+      sessionStorage.removeItem("codebreaker-token");
+      state.watching = "";
+      state.rooms = msg.rooms || [];
+      renderRooms();
+      show("lobby-view");
+      break;
+    case "SESSION":
+      state.token = msg.token;
+      sessionStorage.setItem("codebreaker-token", msg.token);
+      show("game-view");
+      log(msg.text);
+      break;
+    case "WATCHING":
+      state.watching = msg.room;
+      show("game-view");
+      log(msg.text);
+      break;
+    case "SNAPSHOT":
+      show("game-view");
+      state.commitment = msg.snapshot.commitment || "";
+      restore(msg.snapshot);
+      break;
+    case "NEWGAME":
+      if (msg.commitment) {
+        state.commitment = msg.commitment;
+      }
+      // keep the finished board on screen until the new game's first turn
+      state.resetBoard = true;
+      $("secret").hidden = true;
+      log(msg.text);
+      break;
+    case "TURN":
+    case "WAIT":
+      clearBoardIfNewGame();
+      setTurn(msg.type === "TURN" ? "Your turn!" : `${msg.player}'s turn`, msg.type === "TURN", msg.timeLeftSeconds || 0);
+      break;
+    case "RESULT":
+      clearBoardIfNewGame();
+      addGuess(msg.guess, false);
+      break;
+    case "WIN":
+      addGuess(msg.guess, true);
+      setTurn("", false, 0);
+      log(msg.text);
+      verifySecret(state.commitment, msg.salt, msg.secret, msg.codeLength).then(log);
+      break;
+    case "SECRET":
+      $("secret").textContent = msg.text;
+      $("secret").hidden = false;
+      break;
+    case "CHAT":
+      addChat(msg.from, msg.text.replace(`[${msg.from}]: `, ""));
+      break;
+    case "RECOVERY":
+      setTurn("Anyone can guess!", true, 0);
+      log(msg.text);
+      break;
+    case "SHUTDOWN":
+      $("status").textContent = "Server shutting down";
+      log(msg.text);
+      break;
+    default:
+      log(msg.text);
+  }
+}
+
+// verifySecret checks the secret and salt revealed when a game is won against the commitment
+// published when it started: the hex SHA-256 of "salt:secret", the secret written with codeLength digits.
+async function verifySecret(commitment, salt, secret, codeLength) {
+  if (!commitment || !salt || !codeLength) {
+    return "The secret could not be verified: the server sent no commitment.";
+  }
+  if (!window.crypto || !crypto.subtle) {
+    return "The secret could not be verified: this page needs HTTPS or localhost to hash.";
+  }
+  const data = new TextEncoder().encode(`${salt}:${String(secret).padStart(codeLength, "0")}`);
+  const digest = new Uint8Array(await crypto.subtle.digest("SHA-256", data));
+  const hex = Array.from(digest, (b) => b.toString(16).padStart(2, "0")).join("");
+  if (hex !== commitment) {
+    return "Warning: the revealed secret does not match the commitment published when the game started. " +
+      "The secret may have been changed during the game.";
+  }
+  return "Secret verified against the commitment published when the game started.";
+}
+
+function renderRooms() {
+  const body = $("rooms").querySelector("tbody");
+  body.replaceChildren();
+  for (const room of state.rooms) {
+    const row = body.insertRow();
+    const cells = [
+      room.name,
+      `${room.players}/${room.maxPlayers}`,
+      room.spectators,
+      room.codeLength,
+      room.difficulty,
+      `${room.turnTimeSeconds}s`,
+      room.started ? "playing" : "waiting",
+    ];
+    for (const text of cells) {
+      row.insertCell().textContent = text;
+    }
+    const actions = row.insertCell();
+    if (!room.started && room.players < room.maxPlayers) {
+      actions.append(button("Join", () => enterRoom(room, `/join ${room.name}`)));
+    }
+    actions.append(button("Watch", () => enterRoom(room, `/watch ${room.name}`)));
+  }
+}
+
+function button(label, onClick) {
+  const b = document.createElement("button");
+  b.textContent = label;
+  b.onclick = onClick;
+  return b;
+}
+
+function enterRoom(room, command) {
+  state.codeLength = room.codeLength;
+  $("board").querySelector("tbody").replaceChildren();
+  send(command);
+  show("game-view");
+}
+
+function renderScores(scores) {
+  $("scores").textContent = scores.map((s) => `${s.name} ${s.game} (match ${s.match})`).join(" | ");
+  $("scores").hidden = false;
+}
+
+function restore(snapshot) {
+  if (snapshot.scores) {
+    renderScores(snapshot.scores);
+  }
+  $("board").querySelector("tbody").replaceChildren();
+  for (const g of snapshot.history || []) {
+    addGuess(g, false);
+  }
+  if (snapshot.secret) {
+    $("secret").textContent = `The secret is ${snapshot.secret}`;
+    $("secret").hidden = false;
+  }
+  const mine = snapshot.playerId !== 0 && snapshot.currentTurn === snapshot.playerId;
+  setTurn(mine ? "Your turn!" : `${snapshot.currentTurnName}'s turn`, mine, snapshot.timeLeftSeconds);
+}
+
+function clearBoardIfNewGame() {
+  if (state.resetBoard) {
+    state.resetBoard = false;
+    $("board").querySelector("tbody").replaceChildren();
+  }
+}
+
+function addGuess(g, win) {
+  if (!g) {
+    return;
+  }
+  const body = $("board").querySelector("tbody");
+  const row = body.insertRow();
+  if (win) {
+    row.className = "win";
+  }
+  const length = state.codeLength || String(g.guess).length;
+  row.insertCell().textContent = body.rows.length;
+  row.insertCell().textContent = g.playerName;
+  const digits = row.insertCell();
+  digits.className = "digits";
+  digits.textContent = String(g.guess).padStart(length, "0");
+
+  const feedback = row.insertCell();
+  feedback.title = `${g.correctPlace} in place, ${g.wrongPlace} misplaced`;
+  for (let i = 0; i < length; i++) {
+    const peg = document.createElement("span");
+    peg.className = "peg";
+    if (i < g.correctPlace) {
+      peg.classList.add("place");
+    } else if (i < g.correctPlace + g.wrongPlace) {
+      peg.classList.add("wrong");
+    }
+    feedback.append(peg);
+  }
+  row.insertCell().textContent = win ? "Solved!" : g.hint;
+}
+
+function setTurn(text, mine, seconds) {
+  $("turn").textContent = text;
+  $("turn").className = mine ? "mine" : "";
+  $("guess").disabled = !mine;
+  $("guess-form").querySelector("button").disabled = !mine;
+  if (mine) {
+    $("guess").focus();
+  }
+  state.deadline = seconds > 0 ? Date.now() + seconds * 1000 : 0;
+  tick();
+}
+
+function tick() {
+  const timer = $("timer");
+  if (!state.deadline) {
+    timer.textContent = "";
+    return;
+  }
+  const left = Math.max(0, Math.ceil((state.deadline - Date.now()) / 1000));
+  timer.textContent = `${left}s`;
+  timer.className = left <= 5 ? "low" : "";
+}
+
+function addChat(from, text) {
+  const item = document.createElement("li");
+  const sender = document.createElement("span");
+  sender.className = "from";
+  sender.textContent = `${from}: `;
+  item.append(sender, text.trimEnd());
+  $("chat").append(item);
+  $("chat").scrollTop = $("chat").scrollHeight;
+}
+
+function login(register) {
+  state.name = $("nickname").value.trim();
+  const password = $("password").value;
+  $("password").value = "";
+  state.token = "";
+  sessionStorage.removeItem("codebreaker-token");
+  state.watching = "";
+  // an account's password is not kept, so its spectators watch again as guests
+  state.hello = password || register ? "/hello color=false" : `/hello ${state.name} color=false`;
+  if (register) {
+    connect(`/register ${state.name} ${password} color=false`);
+  } else if (password) {
+    connect(`/login ${state.name} ${password} color=false`);
+  } else {
+    connect(`/hello ${state.name} color=false`);
+  }
+}
+
+$("login-form").onsubmit = (event) => {
+  event.preventDefault();
+  login(false);
+};
+
+$("register").onclick = () => login(true);
+
+$("refresh-rooms").onclick = () => send("/rooms");
+
+function roomSettings() {
+  return `players=${$("create-players").value} length=${$("create-length").value} ` +
+    `difficulty=${$("create-difficulty").value} turn=${$("create-turn").value}`;
+}
+
+$("create-form").onsubmit = (event) => {
+  event.preventDefault();
+  state.codeLength = Number($("create-length").value);
+  send(`/create ${$("create-name").value.trim()} ${roomSettings()}`);
+};
+
+$("quick-play").onclick = () => {
+  state.codeLength = Number($("create-length").value);
+  send(`/play ${roomSettings()}`);
+};
+
+$("guess-form").onsubmit = (event) => {
+  event.preventDefault();
+  const guess = $("guess").value.trim();
+  if (guess) {
+    send(guess);
+    $("guess").value = "";
+  }
+};
+
+$("chat-form").onsubmit = (event) => {
+  event.preventDefault();
+  const text = $("chat-input").value.trim();
+  if (text) {
+    send(text.startsWith("/") ? text : `/say ${text}`);
+    $("chat-input").value = "";
+  }
+};
+
+setInterval(tick, 250);
+
+if (state.token) {
+  connect(`/resume ${state.token} color=false`);
+} else {
+  show("login-view");
+}
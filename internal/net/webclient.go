@@ -0,0 +1,20 @@
+package netpkg
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// webFiles is the browser client, a single page that plays over the WebSocket endpoint.
+//
+//go:embed web
+var webFiles embed.FS
+
+func webClientHandler() http.Handler {
+	files, err := fs.Sub(webFiles, "web")
+	if err != nil {
+		panic("embedded web client missing: " + err.Error())
+	}
+	return http.FileServer(http.FS(files))
+}
@@ -0,0 +1,38 @@
+package netpkg
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"code_breaker/internal/game"
+)
+
+func TestWebClient_ServesEmbeddedPage(t *testing.T) {
+	httpServer := httptest.NewServer(newTestHTTPHandler(newTestLobby(t, testConfig(1)), game.ServerConfig{}))
+	t.Cleanup(httpServer.Close)
+
+	for path, contentType := range map[string]string{
+		"/":          "text/html; charset=utf-8",
+		"/app.js":    "text/javascript; charset=utf-8",
+		"/style.css": "text/css; charset=utf-8",
+	} {
+		resp, err := http.Get(httpServer.URL + path)
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode, path)
+		assert.Equal(t, contentType, resp.Header.Get("Content-Type"), path)
+		assert.NotEmpty(t, body, path)
+	}
+
+	resp, err := http.Get(httpServer.URL + "/missing.js")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
@@ -0,0 +1,317 @@
+package netpkg
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebSocket support (RFC 6455). A WebSocket connection carries the same protocol
+// as TCP: every text message from the client is one input line and every
+// server message is sent as one text message holding a JSON game.Message.
+
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+)
+
+const (
+	wsCloseNormal        = 1000
+	wsCloseProtocolError = 1002
+	wsCloseTooBig        = 1009
+)
+
+// wsMaxMessageSize bounds a client message; input lines are short.
+const wsMaxMessageSize = 64 * 1024
+
+var errWebSocketClosed = errors.New("websocket closed")
+
+// wsConn adapts a WebSocket to net.Conn so it can be served like a TCP connection:
+// Read returns the messages received as newline terminated lines and every Write is sent as one text message.
+type wsConn struct {
+	net.Conn
+	reader *bufio.Reader
+	client bool // the client end masks the frames it sends
+
+	pending []byte // rest of the message being read
+
+	mu        sync.Mutex // serializes frames
+	closeSent bool
+	closeOnce sync.Once
+}
+
+func newWebSocketConn(conn net.Conn, reader *bufio.Reader, client bool) *wsConn {
+	return &wsConn{Conn: conn, reader: reader, client: client}
+}
+
+// websocketHandler upgrades HTTP requests to WebSocket connections served by the lobby.
+func websocketHandler(lobby *Lobby) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Sec-WebSocket-Key")
+		switch {
+		case r.Method != http.MethodGet:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		case !headerHasToken(r.Header, "Connection", "upgrade") || !headerHasToken(r.Header, "Upgrade", "websocket"):
+			http.Error(w, "websocket upgrade required", http.StatusUpgradeRequired)
+			return
+		case r.Header.Get("Sec-WebSocket-Version") != "13":
+			w.Header().Set("Sec-WebSocket-Version", "13")
+			http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+			return
+		case key == "":
+			http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+			return
+		}
+
+		hijacker, ok := w.(http.Hijacker)
+		if !ok {
+			http.Error(w, "websocket not supported", http.StatusInternalServerError)
+			return
+		}
+		conn, rw, err := hijacker.Hijack()
+		if err != nil {
+			serverLog.Warn("error upgrading websocket", "remote_addr", r.RemoteAddr, "err", err)
+			return
+		}
+		_, err = fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\n"+
+			"Upgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", websocketAccept(key))
+		if err == nil {
+			err = rw.Flush()
+		}
+		if err != nil {
+			_ = conn.Close()
+			return
+		}
+
+		lobby.metrics.connections.inc("websocket")
+		lobby.Serve(newClientConn(newWebSocketConn(conn, rw.Reader, false), writeTimeout))
+	}
+}
+
+// websocketAccept computes the Sec-WebSocket-Accept answer to a handshake key.
+func websocketAccept(key string) string {
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *wsConn) Read(p []byte) (int, error) {
+	for len(c.pending) == 0 {
+		msg, err := c.readMessage()
+		if err != nil {
+			return 0, err
+		}
+		if len(msg) == 0 || msg[len(msg)-1] != '\n' {
+			msg = append(msg, '\n')
+		}
+		c.pending = msg
+	}
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+func (c *wsConn) Write(p []byte) (int, error) {
+	if err := c.writeFrame(wsOpText, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close sends a close frame, unless one was already sent, and closes the connection.
+func (c *wsConn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		_ = c.Conn.SetWriteDeadline(time.Now().Add(time.Second))
+		_ = c.writeClose(wsCloseNormal)
+		err = c.Conn.Close()
+	})
+	return err
+}
+
+// readMessage reads the frames of the next data message, answering control frames on the way.
+func (c *wsConn) readMessage() ([]byte, error) {
+	var msg []byte
+	started := false
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		switch opcode {
+		case wsOpText, wsOpBinary:
+			if started {
+				return nil, c.fail(wsCloseProtocolError, "expected a continuation frame")
+			}
+			started = true
+			msg = payload
+		case wsOpContinuation:
+			if !started {
+				return nil, c.fail(wsCloseProtocolError, "unexpected continuation frame")
+			}
+			msg = append(msg, payload...)
+		case wsOpClose:
+			_ = c.writeClose(wsCloseNormal)
+			return nil, io.EOF
+		case wsOpPing:
+			if err := c.writeFrame(wsOpPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case wsOpPong:
+			continue
+		default:
+			return nil, c.fail(wsCloseProtocolError, fmt.Sprintf("unknown opcode %d", opcode))
+		}
+
+		if len(msg) > wsMaxMessageSize {
+			return nil, c.fail(wsCloseTooBig, "message too big")
+		}
+		if fin {
+			return msg, nil
+		}
+	}
+}
+
+func (c *wsConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(c.reader, header[:]); err != nil {
+		return
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+
+	if header[0]&0x70 != 0 {
+		err = c.fail(wsCloseProtocolError, "reserved bits set")
+		return
+	}
+	if masked == c.client {
+		// clients must mask their frames and servers must not
+		err = c.fail(wsCloseProtocolError, "wrong frame masking")
+		return
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.reader, ext[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.reader, ext[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if opcode >= wsOpClose && (length > 125 || !fin) {
+		err = c.fail(wsCloseProtocolError, "invalid control frame")
+		return
+	}
+	if length > wsMaxMessageSize {
+		err = c.fail(wsCloseTooBig, "message too big")
+		return
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err = io.ReadFull(c.reader, mask[:]); err != nil {
+			return
+		}
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.reader, payload); err != nil {
+		return
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return
+}
+
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closeSent {
+		return errWebSocketClosed
+	}
+	if opcode == wsOpClose {
+		c.closeSent = true
+	}
+
+	frame := make([]byte, 0, len(payload)+14)
+	frame = append(frame, 0x80|opcode)
+	maskBit := byte(0)
+	if c.client {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, maskBit|byte(n))
+	case n <= 0xFFFF:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+
+	if c.client {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		frame = append(frame, mask[:]...)
+		for i, b := range payload {
+			frame = append(frame, b^mask[i%4])
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+
+	_, err := c.Conn.Write(frame)
+	return err
+}
+
+func (c *wsConn) writeClose(code int) error {
+	return c.writeFrame(wsOpClose, binary.BigEndian.AppendUint16(nil, uint16(code)))
+}
+
+// fail closes the WebSocket with a protocol error code.
+func (c *wsConn) fail(code int, reason string) error {
+	_ = c.writeClose(code)
+	return fmt.Errorf("websocket: %s", reason)
+}
@@ -0,0 +1,76 @@
+package netpkg
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"code_breaker/internal/game"
+)
+
+// dialWebSocket opens a WebSocket to the HTTP server at url.
+func dialWebSocket(t *testing.T, url string) *testClient {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+	_, err = conn.Write([]byte("GET " + WebSocketPath + " HTTP/1.1\r\nHost: localhost\r\n" +
+		"Upgrade: websocket\r\nConnection: keep-alive, Upgrade\r\n" +
+		"Sec-WebSocket-Key: " + key + "\r\nSec-WebSocket-Version: 13\r\n\r\n"))
+	require.NoError(t, err)
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	require.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", resp.Header.Get("Sec-WebSocket-Accept"))
+
+	ws := newWebSocketConn(conn, reader, true)
+	return &testClient{t: t, conn: ws, dec: json.NewDecoder(ws)}
+}
+
+func TestWebSocket_SharesRoomsWithTCP(t *testing.T) {
+	lobby := newTestLobby(t, testConfig(2))
+	addr := serveTestLobby(t, lobby)
+	httpServer := httptest.NewServer(newTestHTTPHandler(lobby, game.ServerConfig{}))
+	t.Cleanup(httpServer.Close)
+
+	browser := dialWebSocket(t, httpServer.URL)
+	browser.send(game.CmdHello + " alice")
+	require.Equal(t, "Welcome alice!\n", browser.expect(game.INFO).Text)
+	browser.send(joinMain)
+	browser.expect(game.SESSION)
+
+	player := dialPlayer(t, addr, "bob", joinMain)
+	player.expect(game.SESSION)
+
+	// both transports see the same game
+	assert.NotEqual(t, browser.expectTurnOrWait(), player.expectTurnOrWait())
+	browser.send(game.CmdSay + " hi bob")
+	assert.Equal(t, "[alice]: hi bob\n", player.expect(game.CHAT).Text)
+	browser.expect(game.CHAT)
+
+	// a ping is answered without disturbing the messages
+	ws := browser.conn.(*wsConn)
+	require.NoError(t, ws.writeFrame(wsOpPing, []byte("ping")))
+	player.send(game.CmdSay + " hi alice")
+	assert.Equal(t, "[bob]: hi alice\n", browser.expect(game.CHAT).Text)
+}
+
+func TestWebSocket_RejectsPlainRequests(t *testing.T) {
+	httpServer := httptest.NewServer(newTestHTTPHandler(newTestLobby(t, testConfig(1)), game.ServerConfig{}))
+	t.Cleanup(httpServer.Close)
+
+	resp, err := http.Get(httpServer.URL + WebSocketPath)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusUpgradeRequired, resp.StatusCode)
+}
@@ -29,7 +29,7 @@ func main() {
 	time.Sleep(2 * time.Second)
 
 	for i := 1; i <= MaxPlayers; i++ {
-		openTerminal("go run ./cmd/client")
+		openTerminal("go run ./cmd/client -room main")
 	}
 }
 